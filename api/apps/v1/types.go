@@ -16,6 +16,12 @@ limitations under the License.
 
 package v1
 
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+)
+
 type Release struct {
 	Name       string `json:"name,omitempty"`
 	Namespace  string `json:"namespace,omitempty"`
@@ -27,6 +33,222 @@ type Release struct {
 }
 
 type ReleaseList struct {
+	// Continue may be set if the list is limited by Max, it is the token to
+	// fetch the next page. It is empty if there are no more results.
+	Continue string `json:"continue,omitempty"`
+
 	// Items is the list of release.
 	Items []Release `json:"items"`
 }
+
+// UninstallReleaseResponse represents a successful uninstall of a release.
+type UninstallReleaseResponse struct {
+	// Release is the uninstalled release, only the name, the namespace and the
+	// status are set. The status is uninstalled, or empty for a dry run.
+	Release *Release `json:"release,omitempty"`
+	// Info is the extra information printed by helm, such as the resources kept
+	// due to the resource policy.
+	Info string `json:"info,omitempty"`
+}
+
+// ReleaseRevision is a single revision in the history of a release.
+type ReleaseRevision struct {
+	Revision    int         `json:"revision"`
+	Updated     metav1.Time `json:"updated"`
+	Status      string      `json:"status,omitempty"`
+	Chart       string      `json:"chart,omitempty"`
+	AppVersion  string      `json:"app_version,omitempty"`
+	Description string      `json:"description,omitempty"`
+}
+
+type ReleaseHistory struct {
+	// Items is the list of release revisions, oldest first.
+	Items []ReleaseRevision `json:"items"`
+}
+
+// ReleaseManifest is the manifest of a release.
+type ReleaseManifest struct {
+	// Manifest is the raw manifest, as printed by `helm get manifest`.
+	Manifest string `json:"manifest,omitempty"`
+	// Objects are the kubernetes objects in the manifest, only set when split
+	// is requested.
+	Objects []unstructured.Unstructured `json:"objects,omitempty"`
+}
+
+// RenderedRelease is a release rendered without being installed.
+type RenderedRelease struct {
+	// Objects are the kubernetes objects in the rendered manifest, hooks excluded.
+	Objects []unstructured.Unstructured `json:"objects,omitempty"`
+	// Hooks are the rendered hooks of the release.
+	Hooks []Hook `json:"hooks,omitempty"`
+	// Notes are the rendered NOTES.txt of the chart.
+	Notes string `json:"notes,omitempty"`
+}
+
+// ReleasePhase describes the state of a release.
+type ReleasePhase string
+
+const (
+	// ReleasePhaseUnknown indicates that a release is in an uncertain state.
+	ReleasePhaseUnknown ReleasePhase = "unknown"
+	// ReleasePhaseDeployed indicates that the release has been pushed to Kubernetes.
+	ReleasePhaseDeployed ReleasePhase = "deployed"
+	// ReleasePhaseUninstalled indicates that a release has been uninstalled from Kubernetes.
+	ReleasePhaseUninstalled ReleasePhase = "uninstalled"
+	// ReleasePhaseSuperseded indicates that this release object is outdated and a newer one exists.
+	ReleasePhaseSuperseded ReleasePhase = "superseded"
+	// ReleasePhaseFailed indicates that the release was not successfully deployed.
+	ReleasePhaseFailed ReleasePhase = "failed"
+	// ReleasePhaseUninstalling indicates that a uninstall operation is underway.
+	ReleasePhaseUninstalling ReleasePhase = "uninstalling"
+	// ReleasePhasePendingInstall indicates that an install operation is underway.
+	ReleasePhasePendingInstall ReleasePhase = "pending-install"
+	// ReleasePhasePendingUpgrade indicates that an upgrade operation is underway.
+	ReleasePhasePendingUpgrade ReleasePhase = "pending-upgrade"
+	// ReleasePhasePendingRollback indicates that an rollback operation is underway.
+	ReleasePhasePendingRollback ReleasePhase = "pending-rollback"
+)
+
+// ReleaseStatus describes a release in detail, as printed by `helm status -o json`.
+type ReleaseStatus struct {
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// Version is the revision of the release.
+	Version int          `json:"version,omitempty"`
+	Info    *ReleaseInfo `json:"info,omitempty"`
+	Chart   *Chart       `json:"chart,omitempty"`
+	// Config is the set of extra values added to the chart, they override the
+	// default values inside of the chart.
+	Config   map[string]interface{} `json:"config,omitempty"`
+	Manifest string                 `json:"manifest,omitempty"`
+	Hooks    []Hook                 `json:"hooks,omitempty"`
+}
+
+// ReleaseInfo describes release information.
+type ReleaseInfo struct {
+	FirstDeployed metav1.Time  `json:"first_deployed,omitempty"`
+	LastDeployed  metav1.Time  `json:"last_deployed,omitempty"`
+	Deleted       metav1.Time  `json:"deleted,omitempty"`
+	Description   string       `json:"description,omitempty"`
+	Status        ReleasePhase `json:"status,omitempty"`
+	Notes         string       `json:"notes,omitempty"`
+}
+
+// Chart is a helm package that contains metadata, default values, and templates.
+type Chart struct {
+	Metadata *ChartMetadata `json:"metadata,omitempty"`
+	// Values are default config for this chart.
+	Values map[string]interface{} `json:"values,omitempty"`
+}
+
+// ChartMetadata is the metadata of a chart, the contents of `Chart.yaml`.
+type ChartMetadata struct {
+	Name         string            `json:"name,omitempty"`
+	Home         string            `json:"home,omitempty"`
+	Sources      []string          `json:"sources,omitempty"`
+	Version      string            `json:"version,omitempty"`
+	Description  string            `json:"description,omitempty"`
+	Keywords     []string          `json:"keywords,omitempty"`
+	Maintainers  []ChartMaintainer `json:"maintainers,omitempty"`
+	Icon         string            `json:"icon,omitempty"`
+	APIVersion   string            `json:"apiVersion,omitempty"`
+	Condition    string            `json:"condition,omitempty"`
+	Tags         string            `json:"tags,omitempty"`
+	AppVersion   string            `json:"appVersion,omitempty"`
+	Deprecated   bool              `json:"deprecated,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	KubeVersion  string            `json:"kubeVersion,omitempty"`
+	Dependencies []ChartDependency `json:"dependencies,omitempty"`
+	Type         string            `json:"type,omitempty"`
+}
+
+// ChartMaintainer describes a chart maintainer.
+type ChartMaintainer struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+// ChartDependency describes a chart upon which another chart depends.
+type ChartDependency struct {
+	Name         string        `json:"name"`
+	Version      string        `json:"version,omitempty"`
+	Repository   string        `json:"repository"`
+	Condition    string        `json:"condition,omitempty"`
+	Tags         []string      `json:"tags,omitempty"`
+	Enabled      bool          `json:"enabled,omitempty"`
+	ImportValues []interface{} `json:"import-values,omitempty"`
+	Alias        string        `json:"alias,omitempty"`
+}
+
+// Hook defines a hook object of a release.
+type Hook struct {
+	Name string `json:"name,omitempty"`
+	// Kind is the Kubernetes kind.
+	Kind string `json:"kind,omitempty"`
+	// Path is the chart-relative path to the template.
+	Path string `json:"path,omitempty"`
+	// Manifest is the manifest contents.
+	Manifest string `json:"manifest,omitempty"`
+	// Events are the events that this hook fires on.
+	Events []string `json:"events,omitempty"`
+	// LastRun indicates the date/time this was last run.
+	LastRun HookExecution `json:"last_run,omitempty"`
+	// Weight indicates the sort order for execution among similar Hook type
+	Weight int `json:"weight,omitempty"`
+	// DeletePolicies are the policies that indicate when to delete the hook
+	DeletePolicies []string `json:"delete_policies,omitempty"`
+}
+
+// HookExecution describes the last execution of a hook.
+type HookExecution struct {
+	StartedAt   metav1.Time `json:"started_at,omitempty"`
+	CompletedAt metav1.Time `json:"completed_at,omitempty"`
+	Phase       string      `json:"phase"`
+}
+
+// Repo is a chart repository.
+type Repo struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type RepoList struct {
+	// Items is the list of chart repository.
+	Items []Repo `json:"items"`
+}
+
+// ChartResult is a chart version found in the chart repositories.
+type ChartResult struct {
+	// Name is the chart name prefixed with the repository name, e.g. "bitnami/nginx".
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	AppVersion  string `json:"app_version,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type ChartResultList struct {
+	// Items is the list of chart versions.
+	Items []ChartResult `json:"items"`
+}
+
+// IndexFile represents the index file in a chart repository.
+type IndexFile struct {
+	APIVersion string      `json:"apiVersion"`
+	Generated  metav1.Time `json:"generated"`
+	// Entries are the chart versions in the repository, keyed by the chart name
+	// and sorted by version from newest to oldest.
+	Entries     map[string][]ChartVersion `json:"entries"`
+	PublicKeys  []string                  `json:"publicKeys,omitempty"`
+	Annotations map[string]string         `json:"annotations,omitempty"`
+}
+
+// ChartVersion represents a chart entry in the IndexFile.
+type ChartVersion struct {
+	ChartMetadata
+
+	URLs    []string    `json:"urls"`
+	Created metav1.Time `json:"created,omitempty"`
+	Removed bool        `json:"removed,omitempty"`
+	Digest  string      `json:"digest,omitempty"`
+}
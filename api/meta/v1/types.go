@@ -16,6 +16,8 @@ limitations under the License.
 
 package v1
 
+import "time"
+
 // CreateOptions may be provided when creating an API object.
 type CreateOptions struct{}
 
@@ -28,6 +30,10 @@ type InstallOptions struct {
 	// generate the name (and omit the NAME parameter)
 	// +optional
 	GenerateName bool `json:"generateName,omitempty"`
+	// Specify template used to name the release (and omit the NAME parameter),
+	// e.g. "preview-{{randAlpha 6 | lower}}"
+	// +optional
+	NameTemplate string `json:"nameTemplate,omitempty"`
 
 	// Specify the exact chart version to use. If this is not specified, the latest version is used
 	// +optional
@@ -43,13 +49,286 @@ type InstallOptions struct {
 	// Set values on the command line
 	// +optional
 	ValuesSets map[string]string `json:"valueSets,omitempty"`
+
+	// Values are passed to helm by stdin as a values file, after the
+	// ValuesFiles and before the ValuesSets, so that their types are kept.
+	// +optional
+	Values map[string]interface{} `json:"values,omitempty"`
+}
+
+// UpgradeOptions may be provided when upgrading a release.
+type UpgradeOptions struct {
+	ChartReference string `json:"chartReference,omitempty"`
+
+	// Specify the exact chart version to use. If this is not specified, the latest version is used
+	// +optional
+	Version *string `json:"version,omitempty"`
+
+	// Specify values in a YAML file or a URL (can specify multiple)
+	// +optional
+	ValuesFiles []string `json:"valuesFiles,omitempty"`
+
+	// Set values on the command line
+	// +optional
+	ValuesSets map[string]string `json:"valueSets,omitempty"`
+
+	// Values are passed to helm by stdin as a values file, after the
+	// ValuesFiles and before the ValuesSets, so that their types are kept.
+	// +optional
+	Values map[string]interface{} `json:"values,omitempty"`
+
+	// If a release by this name doesn't already exist, run an install
+	// +optional
+	Install bool `json:"install,omitempty"`
+	// Create the release namespace if not present, only used together with Install
+	// +optional
+	CreateNamespace bool `json:"createNamespace,omitempty"`
+
+	// When upgrading, reuse the last release's values and merge in any overrides
+	// +optional
+	ReuseValues bool `json:"reuseValues,omitempty"`
+	// When upgrading, reset the values to the ones built into the chart
+	// +optional
+	ResetValues bool `json:"resetValues,omitempty"`
+
+	// If set, upgrade process rolls back changes made in case of failed upgrade.
+	// Atomic implies Wait.
+	// +optional
+	Atomic bool `json:"atomic,omitempty"`
+	// if set, will wait until all resources are in a ready state before marking
+	// the release as successful.
+	// +optional
+	Wait bool `json:"wait,omitempty"`
+	// Time to wait for any individual Kubernetes operation, zero means the helm default
+	// +optional
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// Force resource updates through a replacement strategy
+	// +optional
+	Force bool `json:"force,omitempty"`
+}
+
+// RollbackOptions may be provided when rolling back a release.
+type RollbackOptions struct {
+	// if set, will wait until all resources are in a ready state before marking
+	// the release as successful.
+	// +optional
+	Wait bool `json:"wait,omitempty"`
+	// Time to wait for any individual Kubernetes operation, zero means the helm default
+	// +optional
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// Force resource update through delete/recreate if needed
+	// +optional
+	Force bool `json:"force,omitempty"`
+	// Prevent hooks from running during rollback
+	// +optional
+	DisableHooks bool `json:"disableHooks,omitempty"`
+	// Allow deletion of new resources created in this rollback when rollback fails
+	// +optional
+	CleanupOnFail bool `json:"cleanupOnFail,omitempty"`
+}
+
+// HistoryOptions may be provided when listing the revisions of a release.
+type HistoryOptions struct {
+	// Maximum number of revisions to include in history, zero means the helm default
+	// +optional
+	Max int `json:"max,omitempty"`
+}
+
+// StatusOptions may be provided when getting the status of a release.
+type StatusOptions struct {
+	// If set, display the status of the named release with revision
+	// +optional
+	Revision int `json:"revision,omitempty"`
+}
+
+// GetValuesOptions may be provided when getting the values of a release.
+type GetValuesOptions struct {
+	// Dump all (computed) values rather than only the user supplied values
+	// +optional
+	AllValues bool `json:"allValues,omitempty"`
+	// Get the named release with revision
+	// +optional
+	Revision int `json:"revision,omitempty"`
+}
+
+// GetManifestOptions may be provided when getting the manifest of a release.
+type GetManifestOptions struct {
+	// Get the named release with revision
+	// +optional
+	Revision int `json:"revision,omitempty"`
+	// If set, the manifest is also split into the individual kubernetes objects
+	// +optional
+	Split bool `json:"split,omitempty"`
+}
+
+// GetNotesOptions may be provided when getting the notes of a release.
+type GetNotesOptions struct {
+	// Get the named release with revision
+	// +optional
+	Revision int `json:"revision,omitempty"`
 }
 
-type DeleteOptions struct{}
+// GetHooksOptions may be provided when getting the hooks of a release.
+type GetHooksOptions struct {
+	// Get the named release with revision
+	// +optional
+	Revision int `json:"revision,omitempty"`
+}
+
+// RepoAddOptions may be provided when adding a chart repository.
+type RepoAddOptions struct {
+	// Chart repository username
+	// +optional
+	Username string `json:"username,omitempty"`
+	// Chart repository password, it is passed to helm by stdin
+	// +optional
+	Password string `json:"password,omitempty"`
+	// Verify certificates of HTTPS-enabled servers using this CA bundle
+	// +optional
+	CAFile string `json:"caFile,omitempty"`
+	// Identify HTTPS client using this SSL certificate file
+	// +optional
+	CertFile string `json:"certFile,omitempty"`
+	// Identify HTTPS client using this SSL key file
+	// +optional
+	KeyFile string `json:"keyFile,omitempty"`
+	// Skip tls certificate checks for the repository
+	// +optional
+	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
+	// Replace (overwrite) the repo if it already exists
+	// +optional
+	ForceUpdate bool `json:"forceUpdate,omitempty"`
+}
+
+// RepoIndexOptions may be provided when generating the index file of a chart repository.
+type RepoIndexOptions struct {
+	// URL of the chart repository, the chart urls in the index are relative if not set
+	// +optional
+	URL string `json:"url,omitempty"`
+	// Merge the generated index into the given index file, the generated entries win
+	// +optional
+	Merge string `json:"merge,omitempty"`
+}
+
+// SearchOptions may be provided when searching charts in the chart repositories.
+type SearchOptions struct {
+	// Use regular expressions for searching the keyword
+	// +optional
+	Regexp bool `json:"regexp,omitempty"`
+	// Show all versions of the charts rather than only the latest one
+	// +optional
+	Versions bool `json:"versions,omitempty"`
+	// Use development versions (alpha, beta, and release candidate releases) too.
+	// Equivalent to version '>0.0.0-0'. If Version is set, Devel is ignored.
+	// +optional
+	Devel bool `json:"devel,omitempty"`
+	// Search using semantic versioning constraints on the chart versions, e.g. "^1.2.0"
+	// +optional
+	Version string `json:"version,omitempty"`
+}
+
+// ShowOptions may be provided when showing the information of a chart.
+type ShowOptions struct {
+	// The chart reference, same as the one of InstallOptions
+	ChartReference string `json:"chartReference,omitempty"`
+
+	// Specify the exact chart version to use. If this is not specified, the latest version is used
+	// +optional
+	Version *string `json:"version,omitempty"`
+}
+
+// DeletionPropagation decides if a deletion will propagate to the dependents of
+// the object, and how the garbage collector will handle the propagation.
+type DeletionPropagation string
+
+const (
+	// DeletePropagationOrphan orphans the dependents.
+	DeletePropagationOrphan DeletionPropagation = "orphan"
+	// DeletePropagationBackground deletes the object immediately and the
+	// garbage collector deletes the dependents in the background.
+	DeletePropagationBackground DeletionPropagation = "background"
+	// DeletePropagationForeground deletes the dependents first, then the object.
+	DeletePropagationForeground DeletionPropagation = "foreground"
+)
+
+// DeleteOptions may be provided when uninstalling a release.
+type DeleteOptions struct {
+	// Remove all associated resources and mark the release as deleted, but retain the release history
+	// +optional
+	KeepHistory bool `json:"keepHistory,omitempty"`
+	// Prevent hooks from running during uninstallation
+	// +optional
+	DisableHooks bool `json:"disableHooks,omitempty"`
+	// if set, will wait until all the resources are deleted before returning
+	// +optional
+	Wait bool `json:"wait,omitempty"`
+	// Time to wait for any individual Kubernetes operation, zero means the helm default
+	// +optional
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// Simulate an uninstall
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+	// Selects the deletion cascading strategy for the dependents, defaults to background
+	// +optional
+	Cascade DeletionPropagation `json:"cascade,omitempty"`
+	// Add a custom description
+	// +optional
+	Description string `json:"description,omitempty"`
+}
 
 // GetOptions is the standard query options to the standard REST get call.
 type GetOptions struct {
 	ResourceVersion string `json:"resourceVersion,omitempty"`
 }
 
-type ListOptions struct{}
+// ListOptions is the query options to a standard REST list call.
+type ListOptions struct {
+	// A regular expression, any releases that match the expression will be included
+	// +optional
+	Filter string `json:"filter,omitempty"`
+
+	// Show all releases without any filter applied, helm only shows the deployed
+	// and failed releases by default
+	// +optional
+	All bool `json:"all,omitempty"`
+	// Show the releases with the given statuses, they can be combined
+	// +optional
+	Deployed     bool `json:"deployed,omitempty"`
+	Failed       bool `json:"failed,omitempty"`
+	Pending      bool `json:"pending,omitempty"`
+	Superseded   bool `json:"superseded,omitempty"`
+	Uninstalled  bool `json:"uninstalled,omitempty"`
+	Uninstalling bool `json:"uninstalling,omitempty"`
+
+	// Selector (label query) to filter on, supports '=', '==', and '!=',
+	// e.g. "key1=value1,key2=value2". Works only for secret and configmap storage backends.
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
+	// Sort by release date rather than by name
+	// +optional
+	SortByDate bool `json:"sortByDate,omitempty"`
+	// Reverse the sort order
+	// +optional
+	Reverse bool `json:"reverse,omitempty"`
+
+	// Maximum number of releases to fetch, zero means all of the releases
+	// rather than the default 256 of helm
+	// +optional
+	Max int `json:"max,omitempty"`
+	// Next index in the list, used to offset from start value
+	// +optional
+	Offset int `json:"offset,omitempty"`
+	// Continue is the token returned by a previous list with Max set, it takes
+	// the place of Offset to fetch the next page.
+	// +optional
+	Continue string `json:"continue,omitempty"`
+
+	// PollInterval is the interval between the lists of a watch, since the
+	// watch of the releases is implemented by polling. Zero means 5 seconds,
+	// it is ignored by List.
+	// +optional
+	PollInterval time.Duration `json:"pollInterval,omitempty"`
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// helmsync syncs the repositories and the releases declared in a helmfile
+// spec.
+//
+//	helmsync [flags] sync|diff|destroy
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/caoyingjunz/client-helm/helm"
+	"github.com/caoyingjunz/client-helm/pkg/helmfile"
+	"github.com/caoyingjunz/client-helm/tools/clientcmd"
+)
+
+func main() {
+	var (
+		file             = flag.String("f", "helmfile.yaml", "path of the helmfile spec")
+		kubeconfig       = flag.String("kubeconfig", "", "path of the kubeconfig file, the in-cluster config, $KUBECONFIG or ~/.kube/config is used if empty")
+		kubeContext      = flag.String("context", "", "name of the kubeconfig context to use")
+		concurrency      = flag.Int("concurrency", 0, "number of releases processed at a time, 0 means no limit")
+		waitTimeout      = flag.Duration("wait-timeout", 5*time.Minute, "longest wait for an operation in progress on a release without timeout")
+		detailedExitcode = flag.Bool("detailed-exitcode", false, "exit with 2 if diff finds changes")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] sync|diff|destroy\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	code, err := run(ctx, flag.Arg(0), *file, *kubeconfig, *kubeContext, helmfile.Options{
+		Concurrency: *concurrency,
+		WaitTimeout: *waitTimeout,
+		Out:         os.Stdout,
+	}, *detailedExitcode)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(code)
+}
+
+func run(ctx context.Context, command, file, kubeconfig, kubeContext string, options helmfile.Options, detailedExitcode bool) (int, error) {
+	spec, err := helmfile.Load(file)
+	if err != nil {
+		return 1, err
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return 1, err
+	}
+	config.KubeContext = kubeContext
+	helmClient, err := helm.NewForConfig(config)
+	if err != nil {
+		return 1, err
+	}
+	defer helmClient.Close()
+
+	engine := helmfile.NewEngine(helmClient, options)
+	switch command {
+	case "sync":
+		err = engine.Sync(ctx, spec)
+	case "destroy":
+		err = engine.Destroy(ctx, spec)
+	case "diff":
+		var changes []helmfile.Change
+		if changes, err = engine.Diff(ctx, spec); err != nil {
+			return 1, err
+		}
+		for _, change := range changes {
+			fmt.Printf("%s: %s, %s\n%s", change.Release, change.Action, change.Reason, change.Diff)
+		}
+		if len(changes) != 0 && detailedExitcode {
+			return 2, nil
+		}
+	default:
+		return 1, fmt.Errorf("unknown command %q, expected sync, diff or destroy", command)
+	}
+	if err != nil {
+		return 1, err
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,22 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package release reconciles the releases to their desired state. A Spec
+// declares the chart, the version and the values of a release, the Reconciler
+// plans whether the release must be installed, upgraded, rolled back or left
+// alone, acts through the clientset, and reports the outcome as conditions.
+// The failures are retried with an exponential backoff.
+package release
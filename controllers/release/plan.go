@@ -0,0 +1,187 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	appsv1 "github.com/caoyingjunz/client-helm/helm/typed/apps/v1"
+	utilhelm "github.com/caoyingjunz/client-helm/pkg/util/helm"
+)
+
+// plan compares the release with its spec and returns the action to take:
+//   - the release is installed if it does not exist or was uninstalled;
+//   - nothing is done while another operation is in progress;
+//   - a failed release which already has the desired chart and values is
+//     rolled back to its last successful revision, it would fail again,
+//     unless the rollback is disabled;
+//   - the release is upgraded if its chart or its values differ from the spec.
+func plan(ctx context.Context, releases appsv1.ReleaseInterface, spec Spec, rollback bool) (*Plan, error) {
+	current, err := releases.Get(ctx, spec.Name, metav1.GetOptions{})
+	if err != nil {
+		if utilhelm.IsReleaseNotFound(err) {
+			return &Plan{Action: ActionInstall, Reason: "release not found"}, nil
+		}
+		return nil, err
+	}
+	if isPending(current.Status) || current.Status == string(v1.ReleasePhaseUninstalling) {
+		return &Plan{Action: ActionWait, Reason: fmt.Sprintf("release is %s", current.Status)}, nil
+	}
+	// the release was uninstalled with its history kept
+	if current.Status == string(v1.ReleasePhaseUninstalled) {
+		return &Plan{Action: ActionInstall, Reason: fmt.Sprintf("release is %s", current.Status), replace: true}, nil
+	}
+
+	values, err := releases.GetValues(ctx, spec.Name, metav1.GetValuesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	drift := chartDrift(current.Chart, spec)
+	if len(drift) == 0 && !valuesEqual(values, spec.Values) {
+		drift = "values differ from the spec"
+	}
+
+	if current.Status == string(v1.ReleasePhaseFailed) {
+		if len(drift) != 0 {
+			return &Plan{Action: ActionUpgrade, Reason: fmt.Sprintf("release failed and %s", drift)}, nil
+		}
+		if !rollback {
+			return &Plan{Action: ActionUpgrade, Reason: "release failed, retry the upgrade"}, nil
+		}
+		revision, err := lastSucceededRevision(ctx, releases, spec.Name, current.Revision)
+		if err != nil {
+			return nil, err
+		}
+		if revision == 0 {
+			return &Plan{Action: ActionUpgrade, Reason: "release failed and has no revision to roll back to"}, nil
+		}
+		return &Plan{Action: ActionRollback, Revision: revision, Reason: "release failed with the desired spec"}, nil
+	}
+
+	if len(drift) != 0 {
+		return &Plan{Action: ActionUpgrade, Reason: drift}, nil
+	}
+	return &Plan{Action: ActionNone, Reason: "release is up to date"}, nil
+}
+
+// lastSucceededRevision returns the latest revision before the current one
+// which was deployed, zero if there is none.
+func lastSucceededRevision(ctx context.Context, releases appsv1.ReleaseInterface, name string, current string) (int, error) {
+	currentRevision, err := strconv.Atoi(current)
+	if err != nil {
+		return 0, fmt.Errorf("invalid revision %q of release %q", current, name)
+	}
+	history, err := releases.History(ctx, name, metav1.HistoryOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := len(history.Items) - 1; i >= 0; i-- {
+		item := history.Items[i]
+		if item.Revision >= currentRevision {
+			continue
+		}
+		if item.Status == string(v1.ReleasePhaseDeployed) || item.Status == string(v1.ReleasePhaseSuperseded) {
+			return item.Revision, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func isPending(status string) bool {
+	switch v1.ReleasePhase(status) {
+	case v1.ReleasePhasePendingInstall, v1.ReleasePhasePendingUpgrade, v1.ReleasePhasePendingRollback:
+		return true
+	}
+
+	return false
+}
+
+// chartDrift describes how the chart of the release, printed as NAME-VERSION,
+// differs from the spec. It is empty if the chart matches.
+func chartDrift(chart string, spec Spec) string {
+	name := chartName(spec.Chart)
+	switch {
+	case len(spec.Version) != 0 && len(name) != 0:
+		if chart != name+"-"+spec.Version {
+			return fmt.Sprintf("chart %s differs from %s-%s", chart, name, spec.Version)
+		}
+	case len(spec.Version) != 0:
+		if !strings.HasSuffix(chart, "-"+spec.Version) {
+			return fmt.Sprintf("chart %s differs from version %s", chart, spec.Version)
+		}
+	case len(name) != 0:
+		if !strings.HasPrefix(chart, name+"-") {
+			return fmt.Sprintf("chart %s differs from %s", chart, name)
+		}
+	}
+
+	return ""
+}
+
+// chartName returns the chart name of a chart reference, such as
+// bitnami/nginx or oci://registry/charts/nginx. It is empty for the chart
+// archives, whose names carry the version.
+func chartName(ref string) string {
+	name := path.Base(strings.TrimSuffix(ref, "/"))
+	if strings.HasSuffix(name, ".tgz") || name == "." || name == "/" {
+		return ""
+	}
+
+	return name
+}
+
+// valuesEqual compares the values as helm stores them, so that the types of
+// the spec, such as int, match the ones read back from helm.
+func valuesEqual(current, desired map[string]interface{}) bool {
+	a, err := normalizeValues(current)
+	if err != nil {
+		return false
+	}
+	b, err := normalizeValues(desired)
+	if err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func normalizeValues(values map[string]interface{}) (map[string]interface{}, error) {
+	if len(values) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]interface{}
+	if err = json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
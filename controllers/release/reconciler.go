@@ -0,0 +1,377 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	"github.com/caoyingjunz/client-helm/helm"
+	appsv1 "github.com/caoyingjunz/client-helm/helm/typed/apps/v1"
+	"github.com/caoyingjunz/client-helm/tools/cache"
+)
+
+const (
+	defaultNamespace = "default"
+
+	defaultBaseDelay        = 5 * time.Second
+	defaultMaxDelay         = 5 * time.Minute
+	defaultProgressingDelay = 10 * time.Second
+)
+
+// Options are the options of a Reconciler.
+type Options struct {
+	// BaseDelay and MaxDelay bound the exponential backoff of the failed
+	// reconciles, 5 seconds and 5 minutes by default.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// ProgressingDelay is the wait before a release is reconciled again while
+	// another operation is in progress, 10 seconds by default.
+	ProgressingDelay time.Duration
+	// ResyncPeriod is the interval at which a ready release is reconciled
+	// again, zero disables the resync.
+	ResyncPeriod time.Duration
+	// DisableRollback retries the upgrade of a release which failed with the
+	// desired spec, rather than rolling it back.
+	DisableRollback bool
+
+	// OnStatus is called with the status of a release after each reconcile,
+	// e.g. to write it to a custom resource.
+	OnStatus func(spec Spec, status Status)
+}
+
+// Reconciler brings the releases to their desired state. Reconcile can be
+// called directly, or the specs can be added to the reconciler and processed
+// by Run, which requeues the releases with the delays returned by Reconcile.
+type Reconciler struct {
+	client  helm.Interface
+	options Options
+
+	rateLimiter workqueue.RateLimiter
+	queue       workqueue.DelayingInterface
+
+	lock     sync.RWMutex
+	specs    map[string]Spec
+	statuses map[string]Status
+}
+
+// NewReconciler returns a Reconciler which acts through the client.
+func NewReconciler(client helm.Interface, options Options) *Reconciler {
+	if options.BaseDelay <= 0 {
+		options.BaseDelay = defaultBaseDelay
+	}
+	if options.MaxDelay <= 0 {
+		options.MaxDelay = defaultMaxDelay
+	}
+	if options.ProgressingDelay <= 0 {
+		options.ProgressingDelay = defaultProgressingDelay
+	}
+
+	return &Reconciler{
+		client:      client,
+		options:     options,
+		rateLimiter: workqueue.NewItemExponentialFailureRateLimiter(options.BaseDelay, options.MaxDelay),
+		queue:       workqueue.NewNamedDelayingQueue("release"),
+		specs:       make(map[string]Spec),
+		statuses:    make(map[string]Status),
+	}
+}
+
+// Plan returns the action needed to bring the release to its spec, without
+// taking it.
+func (r *Reconciler) Plan(ctx context.Context, spec Spec) (*Plan, error) {
+	spec = withDefaults(spec)
+	if err := validate(spec); err != nil {
+		return nil, err
+	}
+
+	return plan(ctx, r.client.AppsV1().Releases(spec.Namespace), spec, !r.options.DisableRollback)
+}
+
+// Reconcile plans and takes the action needed to bring the release to its
+// spec, and records the status of the release. A failed reconcile returns
+// the error and a result delayed by the backoff of the release.
+func (r *Reconciler) Reconcile(ctx context.Context, spec Spec) (Result, error) {
+	spec = withDefaults(spec)
+	key := keyOf(spec.Namespace, spec.Name)
+	status, _ := r.Status(spec.Namespace, spec.Name)
+	status.LastReconcileTime = metav1.NewTime(time.Now())
+
+	result, err := r.reconcile(ctx, spec, &status)
+	if err != nil {
+		result.RequeueAfter = r.rateLimiter.When(key)
+	} else if status.LastAction != ActionWait {
+		r.rateLimiter.Forget(key)
+	}
+	status.Failures = r.rateLimiter.NumRequeues(key)
+
+	r.lock.Lock()
+	r.statuses[key] = status
+	r.lock.Unlock()
+	if r.options.OnStatus != nil {
+		r.options.OnStatus(spec, status)
+	}
+
+	return result, err
+}
+
+func (r *Reconciler) reconcile(ctx context.Context, spec Spec, status *Status) (Result, error) {
+	if err := validate(spec); err != nil {
+		status.setNotReady(ReasonReconciliationFailed, err.Error())
+		return Result{}, err
+	}
+
+	releases := r.client.AppsV1().Releases(spec.Namespace)
+	p, err := plan(ctx, releases, spec, !r.options.DisableRollback)
+	if err != nil {
+		status.setNotReady(ReasonReconciliationFailed, err.Error())
+		return Result{}, fmt.Errorf("plan release %s failed %v", spec.Name, err)
+	}
+	status.LastAction = p.Action
+	klog.V(2).Infof("reconcile release %s/%s: %s, %s", spec.Namespace, spec.Name, p.Action, p.Reason)
+
+	switch p.Action {
+	case ActionWait:
+		status.SetCondition(Condition{Type: ConditionReady, Status: ConditionUnknown, Reason: ReasonProgressing, Message: p.Reason})
+		return Result{RequeueAfter: r.options.ProgressingDelay}, nil
+
+	case ActionNone:
+		current, err := releases.Get(ctx, spec.Name, metav1.GetOptions{})
+		if err == nil {
+			status.Revision = current.Revision
+		}
+		status.setReady(ReasonReconciliationSucceeded, p.Reason)
+		return Result{RequeueAfter: r.options.ResyncPeriod}, nil
+
+	case ActionInstall, ActionUpgrade:
+		succeeded, failed := ReasonUpgradeSucceeded, ReasonUpgradeFailed
+		if p.Action == ActionInstall {
+			succeeded, failed = ReasonInstallSucceeded, ReasonInstallFailed
+		}
+
+		rs, err := install(ctx, releases, spec, p)
+		if err != nil {
+			status.SetCondition(Condition{Type: ConditionReleased, Status: ConditionFalse, Reason: failed, Message: err.Error()})
+			status.setNotReady(failed, err.Error())
+			return Result{}, fmt.Errorf("%s release %s failed %v", p.Action, spec.Name, err)
+		}
+		status.Revision = rs.Revision
+		message := fmt.Sprintf("%s of revision %s succeeded", p.Action, rs.Revision)
+		status.SetCondition(Condition{Type: ConditionReleased, Status: ConditionTrue, Reason: succeeded, Message: message})
+		status.setReady(succeeded, message)
+		return Result{RequeueAfter: r.options.ResyncPeriod}, nil
+
+	case ActionRollback:
+		err := releases.Rollback(ctx, spec.Name, p.Revision, metav1.RollbackOptions{Wait: spec.Wait, Timeout: spec.Timeout})
+		if err != nil {
+			status.SetCondition(Condition{Type: ConditionRemediated, Status: ConditionFalse, Reason: ReasonRollbackFailed, Message: err.Error()})
+			status.setNotReady(ReasonRollbackFailed, err.Error())
+			return Result{}, fmt.Errorf("rollback release %s failed %v", spec.Name, err)
+		}
+		if current, err := releases.Get(ctx, spec.Name, metav1.GetOptions{}); err == nil {
+			status.Revision = current.Revision
+		}
+		message := fmt.Sprintf("rolled back to revision %d, %s", p.Revision, p.Reason)
+		status.SetCondition(Condition{Type: ConditionRemediated, Status: ConditionTrue, Reason: ReasonRollbackSucceeded, Message: message})
+		status.setNotReady(ReasonRollbackSucceeded, message)
+		// the release does not match its spec, so the upgrade is retried
+		// after the backoff
+		return Result{}, fmt.Errorf("release %s rolled back to revision %d: %s", spec.Name, p.Revision, p.Reason)
+	}
+
+	return Result{}, fmt.Errorf("unknown action %q", p.Action)
+}
+
+// install installs or upgrades the release, the values of the release are
+// replaced by the ones of the spec.
+func install(ctx context.Context, releases appsv1.ReleaseInterface, spec Spec, p *Plan) (*v1.Release, error) {
+	var version *string
+	if len(spec.Version) != 0 {
+		version = &spec.Version
+	}
+
+	if p.Action == ActionInstall && !p.replace {
+		return releases.Install(ctx, spec.Name, metav1.InstallOptions{
+			ChartReference:  spec.Chart,
+			CreateNamespace: spec.CreateNamespace,
+			Version:         version,
+			Wait:            spec.Wait,
+			Values:          spec.Values,
+		})
+	}
+
+	return releases.Upgrade(ctx, spec.Name, metav1.UpgradeOptions{
+		ChartReference:  spec.Chart,
+		Version:         version,
+		Values:          spec.Values,
+		Install:         p.Action == ActionInstall,
+		CreateNamespace: spec.CreateNamespace,
+		ResetValues:     true,
+		Wait:            spec.Wait,
+		Timeout:         spec.Timeout,
+	})
+}
+
+// Status returns the status recorded by the last reconcile of the release.
+func (r *Reconciler) Status(namespace, name string) (Status, bool) {
+	if len(namespace) == 0 {
+		namespace = defaultNamespace
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	status, ok := r.statuses[keyOf(namespace, name)]
+	if !ok {
+		return Status{}, false
+	}
+	status.Conditions = append([]Condition(nil), status.Conditions...)
+
+	return status, true
+}
+
+// Add adds or replaces the spec of a release and queues it for Run.
+func (r *Reconciler) Add(spec Spec) {
+	spec = withDefaults(spec)
+	key := keyOf(spec.Namespace, spec.Name)
+
+	r.lock.Lock()
+	r.specs[key] = spec
+	r.lock.Unlock()
+	r.queue.Add(key)
+}
+
+// Remove stops reconciling a release, the release is left as it is.
+func (r *Reconciler) Remove(namespace, name string) {
+	if len(namespace) == 0 {
+		namespace = defaultNamespace
+	}
+	key := keyOf(namespace, name)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.specs, key)
+	delete(r.statuses, key)
+	r.rateLimiter.Forget(key)
+}
+
+// EventHandler returns a handler which queues the releases with a spec when
+// they change, to be added to a release informer so that the changes made
+// out of the reconciler are reverted.
+func (r *Reconciler) EventHandler() cache.ResourceEventHandler {
+	enqueue := func(obj interface{}) {
+		release, ok := obj.(*v1.Release)
+		if !ok {
+			return
+		}
+		key := keyOf(release.Namespace, release.Name)
+
+		r.lock.RLock()
+		_, ok = r.specs[key]
+		r.lock.RUnlock()
+		if ok {
+			r.queue.Add(key)
+		}
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) { enqueue(newObj) },
+		DeleteFunc: enqueue,
+	}
+}
+
+// Run reconciles the added releases with the given number of workers until
+// the ctx is done.
+func (r *Reconciler) Run(ctx context.Context, workers int) {
+	defer r.queue.ShutDown()
+
+	for i := 0; i < workers; i++ {
+		go wait.UntilWithContext(ctx, r.runWorker, time.Second)
+	}
+	<-ctx.Done()
+}
+
+func (r *Reconciler) runWorker(ctx context.Context) {
+	for r.processNextItem(ctx) {
+	}
+}
+
+func (r *Reconciler) processNextItem(ctx context.Context) bool {
+	item, quit := r.queue.Get()
+	if quit {
+		return false
+	}
+	defer r.queue.Done(item)
+
+	key := item.(string)
+	r.lock.RLock()
+	spec, ok := r.specs[key]
+	r.lock.RUnlock()
+	if !ok {
+		return true
+	}
+
+	result, err := r.Reconcile(ctx, spec)
+	if err != nil {
+		klog.Errorf("reconcile release %s failed: %v", key, err)
+	}
+	if result.RequeueAfter > 0 {
+		r.queue.AddAfter(key, result.RequeueAfter)
+	}
+
+	return true
+}
+
+func (s *Status) setReady(reason, message string) {
+	s.SetCondition(Condition{Type: ConditionReady, Status: ConditionTrue, Reason: reason, Message: message})
+	s.RemoveCondition(ConditionRemediated)
+}
+
+func (s *Status) setNotReady(reason, message string) {
+	s.SetCondition(Condition{Type: ConditionReady, Status: ConditionFalse, Reason: reason, Message: message})
+}
+
+func withDefaults(spec Spec) Spec {
+	if len(spec.Namespace) == 0 {
+		spec.Namespace = defaultNamespace
+	}
+
+	return spec
+}
+
+func validate(spec Spec) error {
+	if len(spec.Name) == 0 {
+		return fmt.Errorf("release name can not be empty")
+	}
+	if len(spec.Chart) == 0 {
+		return fmt.Errorf("chart reference of release %s can not be empty", spec.Name)
+	}
+
+	return nil
+}
+
+func keyOf(namespace, name string) string {
+	return namespace + "/" + name
+}
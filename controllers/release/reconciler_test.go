@@ -0,0 +1,288 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	"github.com/caoyingjunz/client-helm/helm/fake"
+	helmtesting "github.com/caoyingjunz/client-helm/testing"
+)
+
+func TestReconcileLifecycle(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	reconciler := NewReconciler(client, Options{ResyncPeriod: time.Minute})
+
+	spec := Spec{
+		Name:      "nginx",
+		Namespace: "web",
+		Chart:     "bitnami/nginx",
+		Version:   "1.0.0",
+		Values:    map[string]interface{}{"replicas": 2, "image": map[string]interface{}{"tag": "stable"}},
+	}
+
+	testCases := []struct {
+		name     string
+		update   func(spec *Spec)
+		action   Action
+		reason   string
+		revision string
+	}{
+		{name: "install", action: ActionInstall, reason: ReasonInstallSucceeded, revision: "1"},
+		{name: "up to date", action: ActionNone, reason: ReasonReconciliationSucceeded, revision: "1"},
+		{
+			name:     "values changed",
+			update:   func(spec *Spec) { spec.Values = map[string]interface{}{"replicas": 3} },
+			action:   ActionUpgrade,
+			reason:   ReasonUpgradeSucceeded,
+			revision: "2",
+		},
+		{
+			name:     "version changed",
+			update:   func(spec *Spec) { spec.Version = "1.1.0" },
+			action:   ActionUpgrade,
+			reason:   ReasonUpgradeSucceeded,
+			revision: "3",
+		},
+		{
+			name:     "values removed",
+			update:   func(spec *Spec) { spec.Values = nil },
+			action:   ActionUpgrade,
+			reason:   ReasonUpgradeSucceeded,
+			revision: "4",
+		},
+		{name: "up to date again", action: ActionNone, reason: ReasonReconciliationSucceeded, revision: "4"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.update != nil {
+				tc.update(&spec)
+			}
+			result, err := reconciler.Reconcile(ctx, spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.RequeueAfter != time.Minute {
+				t.Errorf("expected requeue after the resync period, got %v", result.RequeueAfter)
+			}
+
+			status, ok := reconciler.Status("web", "nginx")
+			if !ok {
+				t.Fatalf("expected the status of the release")
+			}
+			ready := status.GetCondition(ConditionReady)
+			if status.LastAction != tc.action || status.Revision != tc.revision || ready == nil || ready.Status != ConditionTrue || ready.Reason != tc.reason {
+				t.Errorf("unexpected status: %+v", status)
+			}
+		})
+	}
+
+	values, err := client.AppsV1().Releases("web").GetValues(ctx, "nginx", metav1.GetValuesOptions{})
+	if err != nil || len(values) != 0 {
+		t.Errorf("unexpected values %v: %v", values, err)
+	}
+	r, err := client.AppsV1().Releases("web").Get(ctx, "nginx", metav1.GetOptions{})
+	if err != nil || r.Chart != "nginx-1.1.0" {
+		t.Errorf("unexpected release %+v: %v", r, err)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset(
+		v1.Release{Name: "nginx", Namespace: "web", Chart: "nginx-1.0.0"},
+		v1.Release{Name: "redis", Namespace: "web", Chart: "redis-1.0.0", Status: "pending-upgrade"},
+		v1.Release{Name: "mysql", Namespace: "web", Chart: "mysql-1.0.0", Revision: "2", Status: "failed"},
+	)
+	if _, err := client.AppsV1().Releases("web").Delete(ctx, "nginx", metav1.DeleteOptions{KeepHistory: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reconciler := NewReconciler(client, Options{})
+
+	testCases := []struct {
+		name    string
+		spec    Spec
+		action  Action
+		replace bool
+	}{
+		{name: "not found", spec: Spec{Name: "mongodb", Chart: "bitnami/mongodb"}, action: ActionInstall},
+		{name: "uninstalled", spec: Spec{Name: "nginx", Chart: "bitnami/nginx"}, action: ActionInstall, replace: true},
+		{name: "pending", spec: Spec{Name: "redis", Chart: "bitnami/redis"}, action: ActionWait},
+		{name: "failed without previous revision", spec: Spec{Name: "mysql", Chart: "bitnami/mysql", Version: "1.0.0"}, action: ActionUpgrade},
+		{name: "failed with spec changed", spec: Spec{Name: "mysql", Chart: "bitnami/mysql", Version: "1.1.0"}, action: ActionUpgrade},
+		{name: "failed chart archive", spec: Spec{Name: "mysql", Chart: "./charts/mysql-1.0.0.tgz", Version: "1.0.0"}, action: ActionUpgrade},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.spec.Namespace = "web"
+			p, err := reconciler.Plan(ctx, tc.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.Action != tc.action || p.replace != tc.replace {
+				t.Errorf("unexpected plan: %+v", p)
+			}
+		})
+	}
+
+	if _, err := reconciler.Plan(ctx, Spec{Name: "nginx"}); err == nil {
+		t.Errorf("expected error for empty chart, got nil")
+	}
+
+	// the failed release is upgraded again, without looking for a revision
+	// to roll back to
+	client.ClearActions()
+	p, err := NewReconciler(client, Options{DisableRollback: true}).Plan(ctx, Spec{Name: "mysql", Namespace: "web", Chart: "bitnami/mysql", Version: "1.0.0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Action != ActionUpgrade || p.Reason != "release failed, retry the upgrade" {
+		t.Errorf("unexpected plan: %+v", p)
+	}
+	for _, action := range client.Actions() {
+		if get, ok := action.(helmtesting.GetAction); ok && get.GetSubresource() == helmtesting.SubresourceHistory {
+			t.Errorf("unexpected history action: %v", action)
+		}
+	}
+}
+
+func TestReconcileRollback(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	releases := client.AppsV1().Releases("web")
+	if _, err := releases.Install(ctx, "nginx", metav1.InstallOptions{ChartReference: "bitnami/nginx", Values: map[string]interface{}{"replicas": 1}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := releases.Upgrade(ctx, "nginx", metav1.UpgradeOptions{ChartReference: "bitnami/nginx", Values: map[string]interface{}{"replicas": 2}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the upgrade to revision 2 failed
+	client.PrependReactor("get", "releases", func(action helmtesting.Action) (bool, interface{}, error) {
+		get := action.(helmtesting.GetAction)
+		if get.GetSubresource() != "" {
+			return false, nil, nil
+		}
+		history, err := client.Tracker().History("web", get.GetName())
+		if err != nil || len(history) != 2 {
+			return false, nil, nil
+		}
+		return true, &v1.Release{Name: "nginx", Namespace: "web", Revision: "2", Status: "failed", Chart: "nginx-"}, nil
+	})
+
+	reconciler := NewReconciler(client, Options{BaseDelay: time.Second})
+	spec := Spec{Name: "nginx", Namespace: "web", Chart: "bitnami/nginx", Values: map[string]interface{}{"replicas": 2}}
+	result, err := reconciler.Reconcile(ctx, spec)
+	if err == nil || result.RequeueAfter != time.Second {
+		t.Errorf("expected the rollback to be retried after the backoff, got %v: %v", result, err)
+	}
+	status, _ := reconciler.Status("web", "nginx")
+	remediated := status.GetCondition(ConditionRemediated)
+	if status.LastAction != ActionRollback || status.Revision != "3" || remediated == nil || remediated.Status != ConditionTrue {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	rollbacks := 0
+	for _, action := range client.Actions() {
+		if rollback, ok := action.(helmtesting.RollbackAction); ok {
+			rollbacks++
+			if rollback.GetRevision() != 1 {
+				t.Errorf("expected rollback to revision 1, got %d", rollback.GetRevision())
+			}
+		}
+	}
+	if rollbacks != 1 {
+		t.Errorf("expected 1 rollback, got %d", rollbacks)
+	}
+
+	// the release is upgraded to its spec again
+	if _, err = reconciler.Reconcile(ctx, spec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	status, _ = reconciler.Status("web", "nginx")
+	if status.LastAction != ActionUpgrade || status.Revision != "4" || status.Failures != 0 || status.GetCondition(ConditionRemediated) != nil {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
+
+func TestReconcileBackoff(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("install", "releases", func(action helmtesting.Action) (bool, interface{}, error) {
+		return true, nil, fmt.Errorf("chart not found")
+	})
+	reconciler := NewReconciler(client, Options{BaseDelay: time.Second, MaxDelay: 3 * time.Second})
+	spec := Spec{Name: "nginx", Chart: "bitnami/nginx"}
+
+	for i, expected := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+		result, err := reconciler.Reconcile(ctx, spec)
+		if err == nil {
+			t.Fatalf("expected error, got nil")
+		}
+		if result.RequeueAfter != expected {
+			t.Errorf("expected requeue after %v, got %v", expected, result.RequeueAfter)
+		}
+		status, _ := reconciler.Status("", "nginx")
+		released := status.GetCondition(ConditionReleased)
+		if status.Failures != i+1 || released == nil || released.Status != ConditionFalse || released.Reason != ReasonInstallFailed {
+			t.Errorf("unexpected status: %+v", status)
+		}
+	}
+}
+
+func TestReconcilerRun(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	statuses := make(chan Status, 10)
+	reconciler := NewReconciler(client, Options{OnStatus: func(spec Spec, status Status) { statuses <- status }})
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go reconciler.Run(ctx, 2)
+	reconciler.Add(Spec{Name: "nginx", Chart: "bitnami/nginx"})
+
+	select {
+	case status := <-statuses:
+		if status.LastAction != ActionInstall {
+			t.Errorf("unexpected status: %+v", status)
+		}
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Fatalf("timed out waiting for the reconcile")
+	}
+
+	// a change made out of the reconciler is reverted
+	if _, err := client.AppsV1().Releases("default").Upgrade(ctx, "nginx", metav1.UpgradeOptions{ChartReference: "bitnami/nginx", ValuesSets: map[string]string{"replicas": "5"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reconciler.EventHandler().OnUpdate(nil, &v1.Release{Name: "nginx", Namespace: "default"})
+	select {
+	case status := <-statuses:
+		if status.LastAction != ActionUpgrade || status.Revision != "3" {
+			t.Errorf("unexpected status: %+v", status)
+		}
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Fatalf("timed out waiting for the reconcile")
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"time"
+
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+)
+
+// Spec is the desired state of a release.
+type Spec struct {
+	Name string
+	// Namespace is the namespace of the release, "default" if empty.
+	Namespace string
+
+	// Chart is the chart reference, e.g. bitnami/nginx.
+	Chart string
+	// Version is the version of the chart, the release is not upgraded for a
+	// newer version of the chart if it is empty.
+	Version string
+	// Values are the user supplied values of the release, they replace the
+	// values of the release rather than being merged into them.
+	Values map[string]interface{}
+
+	// CreateNamespace creates the namespace of the release on install.
+	CreateNamespace bool
+	// Wait waits until the resources are ready before marking the release as
+	// successful.
+	Wait bool
+	// Timeout is the time to wait for any individual kubernetes operation of
+	// an upgrade or a rollback, zero means the helm default.
+	Timeout time.Duration
+}
+
+// Action is what has to be done to bring a release to its desired state.
+type Action string
+
+const (
+	// ActionNone means the release is up to date.
+	ActionNone Action = "None"
+	// ActionInstall means the release does not exist.
+	ActionInstall Action = "Install"
+	// ActionUpgrade means the chart or the values of the release differ from
+	// the spec.
+	ActionUpgrade Action = "Upgrade"
+	// ActionRollback means the release failed with the desired spec, so it is
+	// rolled back to its last successful revision.
+	ActionRollback Action = "Rollback"
+	// ActionWait means another operation is in progress on the release.
+	ActionWait Action = "Wait"
+)
+
+// Plan is the action planned for a release.
+type Plan struct {
+	Action Action
+	// Revision is the revision to roll back to.
+	Revision int
+	// Reason explains why the action is needed.
+	Reason string
+
+	// replace is set if the name of the release is held by an uninstalled
+	// release whose history is kept.
+	replace bool
+}
+
+// Result tells when a release should be reconciled again.
+type Result struct {
+	// RequeueAfter is the wait before the next reconcile, zero means the
+	// release is not reconciled again until its spec or the release changes.
+	RequeueAfter time.Duration
+}
+
+// ConditionType is the type of a condition of a release.
+type ConditionType string
+
+const (
+	// ConditionReady tells whether the release matches its spec.
+	ConditionReady ConditionType = "Ready"
+	// ConditionReleased tells whether the last install or upgrade succeeded.
+	ConditionReleased ConditionType = "Released"
+	// ConditionRemediated tells whether the release has been rolled back after
+	// a failure, it is removed once the release is ready again.
+	ConditionRemediated ConditionType = "Remediated"
+)
+
+// ConditionStatus is the status of a condition.
+type ConditionStatus string
+
+const (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+// The reasons of the conditions.
+const (
+	ReasonInstallSucceeded        = "InstallSucceeded"
+	ReasonInstallFailed           = "InstallFailed"
+	ReasonUpgradeSucceeded        = "UpgradeSucceeded"
+	ReasonUpgradeFailed           = "UpgradeFailed"
+	ReasonRollbackSucceeded       = "RollbackSucceeded"
+	ReasonRollbackFailed          = "RollbackFailed"
+	ReasonReconciliationSucceeded = "ReconciliationSucceeded"
+	ReasonReconciliationFailed    = "ReconciliationFailed"
+	ReasonProgressing             = "Progressing"
+)
+
+// Condition describes the state of a release at a certain point.
+type Condition struct {
+	Type   ConditionType   `json:"type"`
+	Status ConditionStatus `json:"status"`
+	// LastTransitionTime is the last time the condition changed its status.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	Reason             string      `json:"reason,omitempty"`
+	Message            string      `json:"message,omitempty"`
+}
+
+// Status is the observed state of a reconciled release.
+type Status struct {
+	// LastAction is the action taken by the last reconcile.
+	LastAction Action `json:"lastAction,omitempty"`
+	// Revision is the revision of the release after the last reconcile.
+	Revision string `json:"revision,omitempty"`
+	// Failures is the number of consecutive failed reconciles.
+	Failures int `json:"failures,omitempty"`
+	// LastReconcileTime is the time of the last reconcile.
+	LastReconcileTime metav1.Time `json:"lastReconcileTime,omitempty"`
+
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// GetCondition returns the condition of the type, or nil if it is not set.
+func (s *Status) GetCondition(conditionType ConditionType) *Condition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+
+	return nil
+}
+
+// SetCondition adds or updates a condition, the transition time is kept if
+// the status of the condition is unchanged.
+func (s *Status) SetCondition(condition Condition) {
+	existing := s.GetCondition(condition.Type)
+	if existing == nil {
+		if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = metav1.NewTime(time.Now())
+		}
+		s.Conditions = append(s.Conditions, condition)
+		return
+	}
+
+	if existing.Status != condition.Status {
+		existing.Status = condition.Status
+		existing.LastTransitionTime = condition.LastTransitionTime
+		if existing.LastTransitionTime.IsZero() {
+			existing.LastTransitionTime = metav1.NewTime(time.Now())
+		}
+	}
+	existing.Reason = condition.Reason
+	existing.Message = condition.Message
+}
+
+// RemoveCondition removes the condition of the type.
+func (s *Status) RemoveCondition(conditionType ConditionType) {
+	var conditions []Condition
+	for _, condition := range s.Conditions {
+		if condition.Type != conditionType {
+			conditions = append(conditions, condition)
+		}
+	}
+	s.Conditions = conditions
+}
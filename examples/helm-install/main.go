@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"path"
 
 	"k8s.io/client-go/util/homedir"
@@ -28,7 +29,7 @@ import (
 )
 
 func main() {
-	config, err := clientcmd.BuildConfigFromFlags(path.Join(homedir.HomeDir(), ".kube", "config"))
+	config, err := clientcmd.BuildConfigFromFlags("", path.Join(homedir.HomeDir(), ".kube", "config"))
 	if err != nil {
 		panic(err)
 	}
@@ -36,11 +37,15 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	defer helmClient.Close()
 
-	if err = helmClient.AppsV1().Releases("kubez-sysns").Install(context.TODO(), "nginx", metav1.InstallOptions{
+	release, err := helmClient.AppsV1().Releases("kubez-sysns").Install(context.TODO(), "nginx", metav1.InstallOptions{
 		ChartReference:  "bitnami/nginx",
 		CreateNamespace: true,
-	}); err != nil {
+	})
+	if err != nil {
 		panic(err)
 	}
+
+	fmt.Println(fmt.Sprintf("%+v", release))
 }
@@ -29,7 +29,7 @@ import (
 )
 
 func main() {
-	config, err := clientcmd.BuildConfigFromFlags(path.Join(homedir.HomeDir(), ".kube", "config"))
+	config, err := clientcmd.BuildConfigFromFlags("", path.Join(homedir.HomeDir(), ".kube", "config"))
 	if err != nil {
 		panic(err)
 	}
@@ -37,6 +37,7 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	defer helmClient.Close()
 
 	releases, err := helmClient.AppsV1().Releases("kubez-sysns").List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
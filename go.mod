@@ -3,7 +3,11 @@ module github.com/caoyingjunz/client-helm
 go 1.16
 
 require (
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	k8s.io/api v0.22.2
+	k8s.io/apimachinery v0.22.2
 	k8s.io/client-go v0.22.2
 	k8s.io/klog/v2 v2.30.0
 	k8s.io/utils v0.0.0-20211116205334-6203023598ed
+	sigs.k8s.io/yaml v1.2.0
 )
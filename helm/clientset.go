@@ -29,6 +29,7 @@ type Interface interface {
 // version included in a Clientset.
 type Clientset struct {
 	restConfig *rest.Config
+	client     rest.Interface
 	appsV1     v1.AppsV1Interface
 }
 
@@ -36,14 +37,26 @@ func (c *Clientset) AppsV1() v1.AppsV1Interface {
 	return c.appsV1
 }
 
+// Close releases the resources held by the Clientset, such as the temporary
+// kubeconfig file, the Clientset should not be used after being closed.
+func (c *Clientset) Close() error {
+	return c.client.Close()
+}
+
 // NewForConfig creates a new Clientset for the given config.
 func NewForConfig(c *rest.Config) (*Clientset, error) {
-	client := rest.HelmClientFor(*c)
+	client, err := rest.HelmClientFor(*c)
+	if err != nil {
+		return nil, err
+	}
 
-	var cs Clientset
-	var err error
+	cs := Clientset{
+		restConfig: c,
+		client:     client,
+	}
 	cs.appsV1, err = v1.NewForConfig(client)
 	if err != nil {
+		client.Close()
 		return nil, err
 	}
 
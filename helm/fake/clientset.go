@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	"github.com/caoyingjunz/client-helm/helm"
+	appsv1 "github.com/caoyingjunz/client-helm/helm/typed/apps/v1"
+	fakeappsv1 "github.com/caoyingjunz/client-helm/helm/typed/apps/v1/fake"
+	helmtesting "github.com/caoyingjunz/client-helm/testing"
+)
+
+// NewSimpleClientset returns a clientset that will respond with the provided releases.
+// It's backed by a very simple release tracker that records the installs, the
+// upgrades, the rollbacks and the deletes as revisions, like the helm storage
+// does. No helm binary is needed, and the actions are recorded so that the
+// tests can assert on them.
+func NewSimpleClientset(releases ...v1.Release) *Clientset {
+	o := helmtesting.NewReleaseTracker()
+	for _, release := range releases {
+		if err := o.Add(release); err != nil {
+			panic(err)
+		}
+	}
+
+	cs := &Clientset{tracker: o}
+	cs.AddReactor("*", "*", helmtesting.ObjectReaction(o))
+
+	return cs
+}
+
+// Clientset implements helm.Interface. Meant to be embedded into a
+// struct to get a default implementation. This makes faking out just the method
+// you want to test easier.
+type Clientset struct {
+	helmtesting.Fake
+	tracker helmtesting.ReleaseTracker
+}
+
+var _ helm.Interface = &Clientset{}
+
+// Tracker returns the release tracker of the clientset.
+func (c *Clientset) Tracker() helmtesting.ReleaseTracker {
+	return c.tracker
+}
+
+// AppsV1 retrieves the AppsV1Client
+func (c *Clientset) AppsV1() appsv1.AppsV1Interface {
+	return &fakeappsv1.FakeAppsV1{Fake: &c.Fake}
+}
+
+// Close does nothing, it is there to mirror the real Clientset.
+func (c *Clientset) Close() error {
+	return nil
+}
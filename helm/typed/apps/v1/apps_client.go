@@ -23,6 +23,7 @@ import (
 type AppsV1Interface interface {
 	ReleasesGetter
 	ReposGetter
+	ChartsGetter
 }
 
 type AppsV1Client struct {
@@ -37,6 +38,10 @@ func (c *AppsV1Client) Repos(namespace string) RepoInterface {
 	return newRepos(c, namespace)
 }
 
+func (c *AppsV1Client) Charts() ChartInterface {
+	return newCharts(c)
+}
+
 // Client returns a Client that is used to communicate
 // with helm server by this client implementation.
 func (c *AppsV1Client) Client() rest.Interface {
@@ -0,0 +1,148 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	utilhelm "github.com/caoyingjunz/client-helm/pkg/util/helm"
+)
+
+// ChartsGetter has a method to return a ChartInterface.
+// A group's client should implement this interface.
+type ChartsGetter interface {
+	Charts() ChartInterface
+}
+
+// ChartInterface has methods to work with the charts in the chart repositories.
+type ChartInterface interface {
+	Search(ctx context.Context, keyword string, opts metav1.SearchOptions) (*v1.ChartResultList, error)
+	ShowChart(ctx context.Context, opts metav1.ShowOptions) (*v1.ChartMetadata, error)
+	ShowValues(ctx context.Context, opts metav1.ShowOptions) (map[string]interface{}, error)
+	ShowReadme(ctx context.Context, opts metav1.ShowOptions) (string, error)
+	ShowCRDs(ctx context.Context, opts metav1.ShowOptions) ([]unstructured.Unstructured, error)
+	ShowAll(ctx context.Context, opts metav1.ShowOptions) (string, error)
+
+	ChartExpansion
+}
+
+// chart implements ChartInterface
+type chart struct {
+	client utilhelm.Interface
+}
+
+// newCharts returns a chart
+func newCharts(cc *AppsV1Client) *chart {
+	c := cc.Client()
+	return &chart{
+		client: c.GetClient(),
+	}
+}
+
+// Search be equal to command:
+// helm search repo [keyword] [flags]
+// The charts in the local repositories cache are searched, so the repositories
+// may need to be updated first.
+func (c *chart) Search(ctx context.Context, keyword string, opts metav1.SearchOptions) (*v1.ChartResultList, error) {
+	out, err := c.client.SearchRepo(ctx, keyword, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var cs []v1.ChartResult
+	if err = json.Unmarshal(out, &cs); err != nil {
+		return nil, fmt.Errorf("unmarshal to chart failed %v", err)
+	}
+
+	return &v1.ChartResultList{
+		Items: cs,
+	}, nil
+}
+
+// ShowChart be equal to command:
+// helm show chart [CHART] [flags]
+func (c *chart) ShowChart(ctx context.Context, opts metav1.ShowOptions) (*v1.ChartMetadata, error) {
+	out, err := c.client.ShowChart(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var md v1.ChartMetadata
+	if err = yaml.Unmarshal(out, &md); err != nil {
+		return nil, fmt.Errorf("unmarshal to chart metadata failed %v", err)
+	}
+
+	return &md, nil
+}
+
+// ShowValues be equal to command:
+// helm show values [CHART] [flags]
+func (c *chart) ShowValues(ctx context.Context, opts metav1.ShowOptions) (map[string]interface{}, error) {
+	out, err := c.client.ShowValues(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	if err = yaml.Unmarshal(out, &values); err != nil {
+		return nil, fmt.Errorf("unmarshal to chart values failed %v", err)
+	}
+
+	return values, nil
+}
+
+// ShowReadme be equal to command:
+// helm show readme [CHART] [flags]
+func (c *chart) ShowReadme(ctx context.Context, opts metav1.ShowOptions) (string, error) {
+	out, err := c.client.ShowReadme(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+// ShowCRDs be equal to command:
+// helm show crds [CHART] [flags]
+func (c *chart) ShowCRDs(ctx context.Context, opts metav1.ShowOptions) ([]unstructured.Unstructured, error) {
+	out, err := c.client.ShowCRDs(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return splitManifest(out)
+}
+
+// ShowAll be equal to command:
+// helm show all [CHART] [flags]
+// The output mixes yaml and markdown, so it is returned as is, use the other
+// Show methods for the typed information.
+func (c *chart) ShowAll(ctx context.Context, opts metav1.ShowOptions) (string, error) {
+	out, err := c.client.ShowAll(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
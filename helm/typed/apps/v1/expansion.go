@@ -16,6 +16,21 @@ limitations under the License.
 
 package v1
 
-type ReleaseExpansion interface{}
+import (
+	"context"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+)
+
+// The ReleaseExpansion interface allows reading what is actually deployed by a release.
+type ReleaseExpansion interface {
+	GetValues(ctx context.Context, name string, opts metav1.GetValuesOptions) (map[string]interface{}, error)
+	GetManifest(ctx context.Context, name string, opts metav1.GetManifestOptions) (*v1.ReleaseManifest, error)
+	GetNotes(ctx context.Context, name string, opts metav1.GetNotesOptions) (string, error)
+	GetHooks(ctx context.Context, name string, opts metav1.GetHooksOptions) ([]v1.Hook, error)
+}
 
 type RepoExpansion interface{}
+
+type ChartExpansion interface{}
@@ -0,0 +1,46 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	v1 "github.com/caoyingjunz/client-helm/helm/typed/apps/v1"
+	"github.com/caoyingjunz/client-helm/rest"
+	helmtesting "github.com/caoyingjunz/client-helm/testing"
+)
+
+type FakeAppsV1 struct {
+	*helmtesting.Fake
+}
+
+func (c *FakeAppsV1) Releases(namespace string) v1.ReleaseInterface {
+	return &FakeReleases{c, namespace}
+}
+
+func (c *FakeAppsV1) Repos(namespace string) v1.RepoInterface {
+	return &FakeRepos{c, namespace}
+}
+
+func (c *FakeAppsV1) Charts() v1.ChartInterface {
+	return &FakeCharts{c}
+}
+
+// Client returns a Client that is used to communicate
+// with helm server by this client implementation.
+func (c *FakeAppsV1) Client() rest.Interface {
+	var ret *rest.HelmClient
+	return ret
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	helmtesting "github.com/caoyingjunz/client-helm/testing"
+)
+
+// FakeCharts implements ChartInterface, it returns empty results unless a
+// reactor is added for the charts.
+type FakeCharts struct {
+	Fake *FakeAppsV1
+}
+
+func (c *FakeCharts) Search(ctx context.Context, keyword string, opts metav1.SearchOptions) (*v1.ChartResultList, error) {
+	obj, err := c.Fake.Invokes(helmtesting.NewGenericAction(helmtesting.VerbSearch, helmtesting.ChartsResource, "", keyword, opts), &v1.ChartResultList{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.ChartResultList), err
+}
+
+func (c *FakeCharts) ShowChart(ctx context.Context, opts metav1.ShowOptions) (*v1.ChartMetadata, error) {
+	obj, err := c.Fake.Invokes(c.showAction("chart", opts), &v1.ChartMetadata{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.ChartMetadata), err
+}
+
+func (c *FakeCharts) ShowValues(ctx context.Context, opts metav1.ShowOptions) (map[string]interface{}, error) {
+	obj, err := c.Fake.Invokes(c.showAction("values", opts), map[string]interface{}{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(map[string]interface{}), err
+}
+
+func (c *FakeCharts) ShowReadme(ctx context.Context, opts metav1.ShowOptions) (string, error) {
+	obj, err := c.Fake.Invokes(c.showAction("readme", opts), "")
+	if obj == nil {
+		return "", err
+	}
+	return obj.(string), err
+}
+
+func (c *FakeCharts) ShowCRDs(ctx context.Context, opts metav1.ShowOptions) ([]unstructured.Unstructured, error) {
+	obj, err := c.Fake.Invokes(c.showAction("crds", opts), []unstructured.Unstructured{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.([]unstructured.Unstructured), err
+}
+
+func (c *FakeCharts) ShowAll(ctx context.Context, opts metav1.ShowOptions) (string, error) {
+	obj, err := c.Fake.Invokes(c.showAction("all", opts), "")
+	if obj == nil {
+		return "", err
+	}
+	return obj.(string), err
+}
+
+// showAction returns the action of `helm show`, the subresource is the subcommand.
+func (c *FakeCharts) showAction(subresource string, opts metav1.ShowOptions) helmtesting.GenericActionImpl {
+	return helmtesting.NewGenericAction(helmtesting.VerbShow, helmtesting.ChartsResource, subresource, opts.ChartReference, opts)
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	"github.com/caoyingjunz/client-helm/pkg/watch"
+	helmtesting "github.com/caoyingjunz/client-helm/testing"
+)
+
+// FakeReleases implements ReleaseInterface
+type FakeReleases struct {
+	Fake *FakeAppsV1
+	ns   string
+}
+
+func (c *FakeReleases) Create(ctx context.Context, opts metav1.CreateOptions) error {
+	_, err := c.Fake.Invokes(helmtesting.NewCreateAction(c.ns, opts), nil)
+	return err
+}
+
+// Install takes the options of an install, and returns the installed release,
+// or an error, if there is any.
+func (c *FakeReleases) Install(ctx context.Context, name string, opts metav1.InstallOptions) (*v1.Release, error) {
+	obj, err := c.Fake.Invokes(helmtesting.NewInstallAction(c.ns, name, opts), &v1.Release{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.Release), err
+}
+
+// Template takes the options of an install, and returns the rendered release,
+// or an error, if there is any. Nothing is rendered unless a reactor is added.
+func (c *FakeReleases) Template(ctx context.Context, name string, opts metav1.InstallOptions) (*v1.RenderedRelease, error) {
+	obj, err := c.Fake.Invokes(helmtesting.NewTemplateAction(c.ns, name, opts), &v1.RenderedRelease{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.RenderedRelease), err
+}
+
+// Upgrade takes the options of an upgrade, and returns the upgraded release,
+// or an error, if there is any.
+func (c *FakeReleases) Upgrade(ctx context.Context, name string, opts metav1.UpgradeOptions) (*v1.Release, error) {
+	obj, err := c.Fake.Invokes(helmtesting.NewUpgradeAction(c.ns, name, opts), &v1.Release{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.Release), err
+}
+
+// Rollback rolls back the release to the revision, returns an error if one occurs.
+func (c *FakeReleases) Rollback(ctx context.Context, name string, revision int, opts metav1.RollbackOptions) error {
+	_, err := c.Fake.Invokes(helmtesting.NewRollbackAction(c.ns, name, revision, opts), nil)
+	return err
+}
+
+// History takes name of the release, and returns the revisions of the release,
+// or an error, if there is any.
+func (c *FakeReleases) History(ctx context.Context, name string, opts metav1.HistoryOptions) (*v1.ReleaseHistory, error) {
+	obj, err := c.Fake.Invokes(helmtesting.NewGetSubresourceAction(c.ns, helmtesting.SubresourceHistory, name, opts), &v1.ReleaseHistory{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.ReleaseHistory), err
+}
+
+// Delete takes name of the release and uninstalls it. Returns an error if one occurs.
+func (c *FakeReleases) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) (*v1.UninstallReleaseResponse, error) {
+	obj, err := c.Fake.Invokes(helmtesting.NewDeleteAction(c.ns, name, opts), &v1.UninstallReleaseResponse{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.UninstallReleaseResponse), err
+}
+
+// Get takes name of the release, and returns the corresponding release object,
+// and an error if there is any.
+func (c *FakeReleases) Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.Release, error) {
+	obj, err := c.Fake.Invokes(helmtesting.NewGetAction(c.ns, name, opts), &v1.Release{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.Release), err
+}
+
+// Status takes name of the release, and returns the detail of the release,
+// and an error if there is any.
+func (c *FakeReleases) Status(ctx context.Context, name string, opts metav1.StatusOptions) (*v1.ReleaseStatus, error) {
+	obj, err := c.Fake.Invokes(helmtesting.NewGetSubresourceAction(c.ns, helmtesting.SubresourceStatus, name, opts), &v1.ReleaseStatus{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.ReleaseStatus), err
+}
+
+// List takes label and field selectors, and returns the list of Releases that match those selectors.
+func (c *FakeReleases) List(ctx context.Context, opts metav1.ListOptions) (*v1.ReleaseList, error) {
+	obj, err := c.Fake.Invokes(helmtesting.NewListAction(c.ns, opts), &v1.ReleaseList{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.ReleaseList), err
+}
+
+// Watch returns a watch.Interface that watches the releases, the changes are
+// emitted as they are made to the tracker, the list options are not applied.
+func (c *FakeReleases) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	obj, err := c.Fake.Invokes(helmtesting.NewWatchAction(c.ns, opts), nil)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(watch.Interface), err
+}
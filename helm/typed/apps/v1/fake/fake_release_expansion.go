@@ -0,0 +1,57 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	helmtesting "github.com/caoyingjunz/client-helm/testing"
+)
+
+func (c *FakeReleases) GetValues(ctx context.Context, name string, opts metav1.GetValuesOptions) (map[string]interface{}, error) {
+	obj, err := c.Fake.Invokes(helmtesting.NewGetSubresourceAction(c.ns, helmtesting.SubresourceValues, name, opts), map[string]interface{}{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(map[string]interface{}), err
+}
+
+func (c *FakeReleases) GetManifest(ctx context.Context, name string, opts metav1.GetManifestOptions) (*v1.ReleaseManifest, error) {
+	obj, err := c.Fake.Invokes(helmtesting.NewGetSubresourceAction(c.ns, helmtesting.SubresourceManifest, name, opts), &v1.ReleaseManifest{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.ReleaseManifest), err
+}
+
+func (c *FakeReleases) GetNotes(ctx context.Context, name string, opts metav1.GetNotesOptions) (string, error) {
+	obj, err := c.Fake.Invokes(helmtesting.NewGetSubresourceAction(c.ns, helmtesting.SubresourceNotes, name, opts), "")
+	if obj == nil {
+		return "", err
+	}
+	return obj.(string), err
+}
+
+func (c *FakeReleases) GetHooks(ctx context.Context, name string, opts metav1.GetHooksOptions) ([]v1.Hook, error) {
+	obj, err := c.Fake.Invokes(helmtesting.NewGetSubresourceAction(c.ns, helmtesting.SubresourceHooks, name, opts), []v1.Hook{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.([]v1.Hook), err
+}
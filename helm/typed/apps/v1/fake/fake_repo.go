@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	helmtesting "github.com/caoyingjunz/client-helm/testing"
+)
+
+// FakeRepos implements RepoInterface
+type FakeRepos struct {
+	Fake *FakeAppsV1
+	ns   string
+}
+
+func (c *FakeRepos) Add(ctx context.Context, repo v1.Repo, opts metav1.RepoAddOptions) error {
+	_, err := c.Fake.Invokes(helmtesting.NewRepoAddAction(repo, opts), nil)
+	return err
+}
+
+// Index returns an empty index file unless a reactor is added, the directory
+// is not read.
+func (c *FakeRepos) Index(ctx context.Context, dir string, opts metav1.RepoIndexOptions) (*v1.IndexFile, error) {
+	obj, err := c.Fake.Invokes(helmtesting.NewGenericAction(helmtesting.VerbIndex, helmtesting.ReposResource, "", dir, opts), &v1.IndexFile{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.IndexFile), err
+}
+
+func (c *FakeRepos) List(ctx context.Context) (*v1.RepoList, error) {
+	obj, err := c.Fake.Invokes(helmtesting.NewGenericAction(helmtesting.VerbList, helmtesting.ReposResource, "", "", nil), &v1.RepoList{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.RepoList), err
+}
+
+func (c *FakeRepos) Remove(ctx context.Context, names ...string) error {
+	_, err := c.Fake.Invokes(helmtesting.NewGenericAction(helmtesting.VerbRemove, helmtesting.ReposResource, "", "", names), nil)
+	return err
+}
+
+func (c *FakeRepos) Update(ctx context.Context, names ...string) error {
+	_, err := c.Fake.Invokes(helmtesting.NewGenericAction(helmtesting.VerbUpdate, helmtesting.ReposResource, "", "", names), nil)
+	return err
+}
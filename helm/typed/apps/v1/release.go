@@ -20,14 +20,22 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/caoyingjunz/client-helm/api/apps/v1"
 	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	"github.com/caoyingjunz/client-helm/internal/releaseutil"
 	utilhelm "github.com/caoyingjunz/client-helm/pkg/util/helm"
+	"github.com/caoyingjunz/client-helm/pkg/watch"
 )
 
 const (
 	defaultNamespace = "default"
+
+	// defaultPollInterval is the interval between the lists of a watch.
+	defaultPollInterval = 5 * time.Second
 )
 
 // ReleasesGetter A group's client should implement this interface.
@@ -38,10 +46,16 @@ type ReleasesGetter interface {
 // ReleaseInterface has methods to work with release resources.
 type ReleaseInterface interface {
 	Create(ctx context.Context, opts metav1.CreateOptions) error
-	Install(ctx context.Context, name string, opts metav1.InstallOptions) error
-	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	Install(ctx context.Context, name string, opts metav1.InstallOptions) (*v1.Release, error)
+	Template(ctx context.Context, name string, opts metav1.InstallOptions) (*v1.RenderedRelease, error)
+	Upgrade(ctx context.Context, name string, opts metav1.UpgradeOptions) (*v1.Release, error)
+	Rollback(ctx context.Context, name string, revision int, opts metav1.RollbackOptions) error
+	History(ctx context.Context, name string, opts metav1.HistoryOptions) (*v1.ReleaseHistory, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) (*v1.UninstallReleaseResponse, error)
 	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.Release, error)
+	Status(ctx context.Context, name string, opts metav1.StatusOptions) (*v1.ReleaseStatus, error)
 	List(ctx context.Context, opts metav1.ListOptions) (*v1.ReleaseList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
 
 	ReleaseExpansion
 }
@@ -67,21 +81,118 @@ func (c *release) Create(ctx context.Context, opts metav1.CreateOptions) error {
 }
 
 // Install This command installs a chart archive.
-// The install argument must be a chart reference for now
-func (c *release) Install(ctx context.Context, name string, opts metav1.InstallOptions) error {
-	return c.client.Install(c.ns, name, opts)
+// The install argument must be a chart reference for now, the name must be
+// empty if GenerateName or NameTemplate is set, the returned release carries
+// the generated name.
+func (c *release) Install(ctx context.Context, name string, opts metav1.InstallOptions) (*v1.Release, error) {
+	out, err := c.client.Install(ctx, c.ns, name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var rs v1.ReleaseStatus
+	if err = json.Unmarshal(out, &rs); err != nil {
+		return nil, fmt.Errorf("unmarshal to release failed %v", err)
+	}
+
+	return releaseutil.FromStatus(&rs), nil
+}
+
+// Template be equal to command:
+// helm install [NAME] [CHART] --dry-run [flags]
+// The release is rendered against the cluster without being installed, so
+// that what it would create can be checked before the real Install runs.
+func (c *release) Template(ctx context.Context, name string, opts metav1.InstallOptions) (*v1.RenderedRelease, error) {
+	out, err := c.client.Template(ctx, c.ns, name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var rs v1.ReleaseStatus
+	if err = json.Unmarshal(out, &rs); err != nil {
+		return nil, fmt.Errorf("unmarshal to release failed %v", err)
+	}
+
+	objects, err := splitManifest([]byte(rs.Manifest))
+	if err != nil {
+		return nil, err
+	}
+	rr := &v1.RenderedRelease{
+		Objects: objects,
+		Hooks:   rs.Hooks,
+	}
+	if rs.Info != nil {
+		rr.Notes = rs.Info.Notes
+	}
+
+	return rr, nil
+}
+
+// Upgrade be equal to command:
+// helm upgrade [RELEASE] [CHART] [flags]
+func (c *release) Upgrade(ctx context.Context, name string, opts metav1.UpgradeOptions) (*v1.Release, error) {
+	out, err := c.client.Upgrade(ctx, c.ns, name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var rs v1.ReleaseStatus
+	if err = json.Unmarshal(out, &rs); err != nil {
+		return nil, fmt.Errorf("unmarshal to release failed %v", err)
+	}
+
+	return releaseutil.FromStatus(&rs), nil
+}
+
+// Rollback be equal to command:
+// helm rollback <RELEASE> [REVISION] [flags]
+// A zero revision rolls back to the previous release.
+func (c *release) Rollback(ctx context.Context, name string, revision int, opts metav1.RollbackOptions) error {
+	return c.client.Rollback(ctx, c.ns, name, revision, opts)
+}
+
+// History be equal to command:
+// helm history RELEASE_NAME [flags]
+func (c *release) History(ctx context.Context, name string, opts metav1.HistoryOptions) (*v1.ReleaseHistory, error) {
+	out, err := c.client.History(ctx, c.ns, name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []v1.ReleaseRevision
+	if err = json.Unmarshal(out, &rs); err != nil {
+		return nil, fmt.Errorf("unmarshal to release history failed %v", err)
+	}
+
+	return &v1.ReleaseHistory{
+		Items: rs,
+	}, nil
 }
 
 // Delete be equal to command:
 // helm uninstall RELEASE_NAME [...] [flags]
 // Aliases:
-//   uninstall, del, delete, un
-func (c *release) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
-	return c.client.Delete(c.ns, name, opts)
+//
+//	uninstall, del, delete, un
+//
+// helm does not print the uninstalled release, so the release of the response
+// only carries the name, the namespace and the status.
+func (c *release) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) (*v1.UninstallReleaseResponse, error) {
+	out, err := c.client.Delete(ctx, c.ns, name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.UninstallReleaseResponse{
+		Release: uninstalledRelease(c.ns, name, opts),
+		Info:    uninstallInfo(out, name),
+	}, nil
 }
 
+// Get returns the latest revision of the release whatever its state, such as
+// pending or uninstalled with its history kept.
 func (c *release) Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.Release, error) {
-	out, err := c.client.Get(c.ns, name)
+	out, err := c.client.Get(ctx, c.ns, name)
 	if err != nil {
 		return nil, err
 	}
@@ -97,9 +208,35 @@ func (c *release) Get(ctx context.Context, name string, opts metav1.GetOptions)
 	return &hs[0], nil
 }
 
+// Status be equal to command:
+// helm status RELEASE_NAME [flags]
+func (c *release) Status(ctx context.Context, name string, opts metav1.StatusOptions) (*v1.ReleaseStatus, error) {
+	out, err := c.client.Status(ctx, c.ns, name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var rs v1.ReleaseStatus
+	if err = json.Unmarshal(out, &rs); err != nil {
+		return nil, fmt.Errorf("unmarshal to release status failed %v", err)
+	}
+
+	return &rs, nil
+}
+
 // List returns the list of Helms that match those ns
+// If Max is set, the returned list carries a Continue token as long as the
+// page is full, pass it back in the options to fetch the next page.
 func (c *release) List(ctx context.Context, opts metav1.ListOptions) (*v1.ReleaseList, error) {
-	out, err := c.client.List(c.ns)
+	if len(opts.Continue) != 0 {
+		offset, err := strconv.Atoi(opts.Continue)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("invalid continue token %q", opts.Continue)
+		}
+		opts.Offset = offset
+	}
+
+	out, err := c.client.List(ctx, c.ns, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -109,7 +246,63 @@ func (c *release) List(ctx context.Context, opts metav1.ListOptions) (*v1.Releas
 		return nil, fmt.Errorf("unmarshal to release failed %v", err)
 	}
 
-	return &v1.ReleaseList{
+	list := &v1.ReleaseList{
 		Items: hs,
-	}, nil
+	}
+	if opts.Max > 0 && len(hs) == opts.Max {
+		list.Continue = strconv.Itoa(opts.Offset + len(hs))
+	}
+
+	return list, nil
+}
+
+// Watch returns a watch of the releases that match the options. helm has no
+// watch, the releases are listed every opts.PollInterval and the changes are
+// emitted, the existing releases are emitted as added first. The paging
+// options are ignored. The releases in every state are watched unless a state
+// is set in the options, so that a release which goes pending is emitted as
+// modified rather than deleted.
+func (c *release) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	opts.Max, opts.Offset, opts.Continue = 0, 0, ""
+	if !releaseutil.HasStates(opts) {
+		opts.All = true
+	}
+
+	return watch.NewPoller(ctx, interval, func(ctx context.Context) ([]v1.Release, error) {
+		list, err := c.List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return list.Items, nil
+	}), nil
+}
+
+// uninstalledRelease returns the release of an uninstall response, the status
+// is left empty for a dry run as the release is not changed.
+func uninstalledRelease(namespace, name string, opts metav1.DeleteOptions) *v1.Release {
+	r := &v1.Release{Name: name, Namespace: namespace}
+	if !opts.DryRun {
+		r.Status = string(v1.ReleasePhaseUninstalled)
+	}
+
+	return r
+}
+
+// uninstallInfo returns the extra information in the output of `helm uninstall`,
+// which is followed by the `release "NAME" uninstalled` line.
+func uninstallInfo(out []byte, name string) string {
+	done := fmt.Sprintf("release %q uninstalled", name)
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if strings.TrimSpace(line) != done {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
 }
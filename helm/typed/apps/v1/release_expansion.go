@@ -0,0 +1,218 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+)
+
+const (
+	sourceCommentPrefix = "# Source: "
+
+	hookAnnotation             = "helm.sh/hook"
+	hookWeightAnnotation       = "helm.sh/hook-weight"
+	hookDeletePolicyAnnotation = "helm.sh/hook-delete-policy"
+)
+
+// GetValues be equal to command:
+// helm get values RELEASE_NAME [flags]
+func (c *release) GetValues(ctx context.Context, name string, opts metav1.GetValuesOptions) (map[string]interface{}, error) {
+	out, err := c.client.GetValues(ctx, c.ns, name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	if err = json.Unmarshal(out, &values); err != nil {
+		return nil, fmt.Errorf("unmarshal to release values failed %v", err)
+	}
+
+	return values, nil
+}
+
+// GetManifest be equal to command:
+// helm get manifest RELEASE_NAME [flags]
+func (c *release) GetManifest(ctx context.Context, name string, opts metav1.GetManifestOptions) (*v1.ReleaseManifest, error) {
+	out, err := c.client.GetManifest(ctx, c.ns, name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rm := &v1.ReleaseManifest{
+		Manifest: string(out),
+	}
+	if opts.Split {
+		if rm.Objects, err = splitManifest(out); err != nil {
+			return nil, err
+		}
+	}
+
+	return rm, nil
+}
+
+// GetNotes be equal to command:
+// helm get notes RELEASE_NAME [flags]
+func (c *release) GetNotes(ctx context.Context, name string, opts metav1.GetNotesOptions) (string, error) {
+	out, err := c.client.GetNotes(ctx, c.ns, name, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+// GetHooks be equal to command:
+// helm get hooks RELEASE_NAME [flags]
+// The hook details are parsed from the `# Source:` comments and the helm
+// annotations of each hook manifest.
+func (c *release) GetHooks(ctx context.Context, name string, opts metav1.GetHooksOptions) ([]v1.Hook, error) {
+	out, err := c.client.GetHooks(ctx, c.ns, name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := splitDocuments(out)
+	if err != nil {
+		return nil, err
+	}
+	hooks := make([]v1.Hook, 0, len(docs))
+	for _, doc := range docs {
+		obj, err := decodeObject(doc)
+		if err != nil {
+			return nil, err
+		}
+		if obj == nil {
+			continue
+		}
+
+		hook := v1.Hook{
+			Name:     obj.GetName(),
+			Kind:     obj.GetKind(),
+			Path:     sourcePath(doc),
+			Manifest: strings.TrimSpace(string(doc)),
+		}
+		annotations := obj.GetAnnotations()
+		if events, ok := annotations[hookAnnotation]; ok {
+			hook.Events = splitAnnotation(events)
+		}
+		if weight, ok := annotations[hookWeightAnnotation]; ok {
+			// helm takes an invalid weight as zero as well
+			hook.Weight, _ = strconv.Atoi(strings.TrimSpace(weight))
+		}
+		if policies, ok := annotations[hookDeletePolicyAnnotation]; ok {
+			hook.DeletePolicies = splitAnnotation(policies)
+		}
+		hooks = append(hooks, hook)
+	}
+
+	return hooks, nil
+}
+
+// splitManifest splits a multi-document manifest into the kubernetes objects,
+// documents without any object are skipped.
+func splitManifest(manifest []byte) ([]unstructured.Unstructured, error) {
+	docs, err := splitDocuments(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]unstructured.Unstructured, 0, len(docs))
+	for _, doc := range docs {
+		obj, err := decodeObject(doc)
+		if err != nil {
+			return nil, err
+		}
+		if obj != nil {
+			objects = append(objects, *obj)
+		}
+	}
+
+	return objects, nil
+}
+
+// splitDocuments splits a multi-document yaml by the `---` separators.
+func splitDocuments(data []byte) ([][]byte, error) {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+
+	var docs [][]byte
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("split manifest failed %v", err)
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// decodeObject decodes a single yaml document, it returns nil if the document
+// only contains comments.
+func decodeObject(doc []byte) (*unstructured.Unstructured, error) {
+	data, err := utilyaml.ToJSON(doc)
+	if err != nil {
+		return nil, fmt.Errorf("convert manifest to json failed %v", err)
+	}
+
+	var object map[string]interface{}
+	if err = utiljson.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("unmarshal to kubernetes object failed %v", err)
+	}
+	if len(object) == 0 {
+		return nil, nil
+	}
+
+	return &unstructured.Unstructured{Object: object}, nil
+}
+
+// sourcePath returns the chart-relative template path from the `# Source:`
+// comment that helm writes in front of each document.
+func sourcePath(doc []byte) string {
+	for _, line := range strings.Split(string(doc), "\n") {
+		if strings.HasPrefix(line, sourceCommentPrefix) {
+			return strings.TrimSpace(strings.TrimPrefix(line, sourceCommentPrefix))
+		}
+	}
+
+	return ""
+}
+
+func splitAnnotation(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); len(item) != 0 {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	utilhelm "github.com/caoyingjunz/client-helm/pkg/util/helm"
+	helmtesting "github.com/caoyingjunz/client-helm/pkg/util/helm/testing"
+	"github.com/caoyingjunz/client-helm/rest"
+)
+
+func TestReleaseDelete(t *testing.T) {
+	testCases := []struct {
+		name   string
+		opts   metav1.DeleteOptions
+		out    string
+		err    error
+		status string
+		info   string
+	}{
+		{
+			name:   "uninstall",
+			out:    "release \"nginx\" uninstalled\n",
+			status: string(v1.ReleasePhaseUninstalled),
+		},
+		{
+			name:   "kept resources",
+			out:    "These resources were kept due to the resource policy:\n[PersistentVolumeClaim] data\n\nrelease \"nginx\" uninstalled\n",
+			status: string(v1.ReleasePhaseUninstalled),
+			info:   "These resources were kept due to the resource policy:\n[PersistentVolumeClaim] data",
+		},
+		{
+			name: "dry run",
+			opts: metav1.DeleteOptions{DryRun: true},
+			out:  "release \"nginx\" uninstalled\n",
+		},
+		{
+			name: "not found",
+			err:  utilhelm.ErrReleaseNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeHelm := helmtesting.NewFakeHelm()
+			fakeHelm.SetResponse("Delete", []byte(tc.out), tc.err)
+			client, _ := NewForConfig(&rest.HelmClient{Client: fakeHelm})
+
+			resp, err := client.Releases("web").Delete(context.TODO(), "nginx", tc.opts)
+			if tc.err != nil {
+				if !utilhelm.IsReleaseNotFound(err) {
+					t.Errorf("expected release not found error, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else {
+				r := resp.Release
+				if r.Name != "nginx" || r.Namespace != "web" || r.Status != tc.status || resp.Info != tc.info {
+					t.Errorf("unexpected response: %+v, %+v", r, resp)
+				}
+			}
+
+			// the release is uninstalled without being looked up first
+			calls := fakeHelm.GetCalls()
+			if len(calls) != 1 || calls[0].Method != "Delete" {
+				t.Errorf("unexpected calls: %+v", calls)
+			}
+		})
+	}
+}
+
+func TestReleaseWatchStates(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     metav1.ListOptions
+		expected metav1.ListOptions
+	}{
+		{
+			name:     "no states",
+			opts:     metav1.ListOptions{Filter: "^nginx$", Max: 10},
+			expected: metav1.ListOptions{Filter: "^nginx$", All: true},
+		},
+		{
+			name:     "pending",
+			opts:     metav1.ListOptions{Pending: true},
+			expected: metav1.ListOptions{Pending: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeHelm := helmtesting.NewFakeHelm()
+			fakeHelm.SetResponse("List", []byte("[]"), nil)
+			client, _ := NewForConfig(&rest.HelmClient{Client: fakeHelm})
+
+			tc.opts.PollInterval = time.Hour
+			w, err := client.Releases("web").Watch(context.TODO(), tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer w.Stop()
+
+			var calls []helmtesting.FakeCall
+			err = wait.PollImmediate(time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+				calls = fakeHelm.GetCalls()
+				return len(calls) != 0, nil
+			})
+			if err != nil {
+				t.Fatalf("timed out waiting for the list")
+			}
+			tc.expected.PollInterval = time.Hour
+			if opts := calls[0].Options.(metav1.ListOptions); opts != tc.expected {
+				t.Errorf("expected list options %+v, got %+v", tc.expected, opts)
+			}
+		})
+	}
+}
@@ -18,8 +18,15 @@ package v1
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
 
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
 	utilhelm "github.com/caoyingjunz/client-helm/pkg/util/helm"
+	utilrepo "github.com/caoyingjunz/client-helm/pkg/util/repo"
 )
 
 // A group's client should implement this interface.
@@ -28,11 +35,11 @@ type ReposGetter interface {
 }
 
 type RepoInterface interface {
-	Add(ctx context.Context) error    // add a chart repository
-	Index(ctx context.Context) error  // generate an index file given a directory containing packaged charts
-	List(ctx context.Context) error   // list chart repositories
-	Remove(ctx context.Context) error // remove one or more chart repositories
-	Update(ctx context.Context) error // update information of available charts locally from chart repositories
+	Add(ctx context.Context, repo v1.Repo, opts metav1.RepoAddOptions) error                    // add a chart repository
+	Index(ctx context.Context, dir string, opts metav1.RepoIndexOptions) (*v1.IndexFile, error) // generate an index file given a directory containing packaged charts
+	List(ctx context.Context) (*v1.RepoList, error)                                             // list chart repositories
+	Remove(ctx context.Context, names ...string) error                                          // remove one or more chart repositories
+	Update(ctx context.Context, names ...string) error                                          // update information of available charts locally from chart repositories
 
 	RepoExpansion
 }
@@ -50,22 +57,71 @@ func newRepos(cc *AppsV1Client, namespace string) *repo {
 	}
 }
 
-func (c *repo) Add(ctx context.Context) error {
-	return nil
+// Add be equal to command:
+// helm repo add [NAME] [URL] [flags]
+func (c *repo) Add(ctx context.Context, repo v1.Repo, opts metav1.RepoAddOptions) error {
+	return c.client.RepoAdd(ctx, repo.Name, repo.URL, opts)
 }
 
-func (c *repo) Index(ctx context.Context) error {
-	return nil
+// Index be equal to command:
+// helm repo index [DIR] [flags]
+// The index file is generated in pure go and written to DIR/index.yaml, so
+// that the helm binary is not required.
+func (c *repo) Index(ctx context.Context, dir string, opts metav1.RepoIndexOptions) (*v1.IndexFile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	index, err := utilrepo.IndexDirectory(dir, opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("index directory %s failed %v", dir, err)
+	}
+	if len(opts.Merge) != 0 {
+		// Nothing to merge if the index to merge into doesn't exist yet
+		mergeIndex, err := utilrepo.LoadIndexFile(opts.Merge)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			utilrepo.Merge(index, mergeIndex)
+			utilrepo.SortEntries(index)
+		}
+	}
+
+	if err = utilrepo.WriteIndexFile(index, filepath.Join(dir, utilrepo.IndexFileName)); err != nil {
+		return nil, err
+	}
+
+	return index, nil
 }
 
-func (c *repo) List(ctx context.Context) error {
-	return nil
+// List be equal to command:
+// helm repo list [flags]
+func (c *repo) List(ctx context.Context) (*v1.RepoList, error) {
+	out, err := c.client.RepoList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []v1.Repo
+	if err = json.Unmarshal(out, &rs); err != nil {
+		return nil, fmt.Errorf("unmarshal to repo failed %v", err)
+	}
+
+	return &v1.RepoList{
+		Items: rs,
+	}, nil
 }
 
-func (c *repo) Remove(ctx context.Context) error {
-	return nil
+// Remove be equal to command:
+// helm repo remove [REPO1 [REPO2 ...]] [flags]
+func (c *repo) Remove(ctx context.Context, names ...string) error {
+	return c.client.RepoRemove(ctx, names...)
 }
 
-func (c *repo) Update(ctx context.Context) error {
-	return nil
+// Update be equal to command:
+// helm repo update [REPO1 [REPO2 ...]] [flags]
+// All of the repositories are updated if no names are given.
+func (c *repo) Update(ctx context.Context, names ...string) error {
+	return c.client.RepoUpdate(ctx, names...)
 }
@@ -1 +1,286 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
 package helm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	"github.com/caoyingjunz/client-helm/helm/fake"
+	utilhelm "github.com/caoyingjunz/client-helm/pkg/util/helm"
+	helmtesting "github.com/caoyingjunz/client-helm/testing"
+)
+
+func TestFakeClientsetSeed(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset(v1.Release{
+		Name:       "nginx",
+		Namespace:  "web",
+		Revision:   "3",
+		Chart:      "nginx-ingress-1.2.3",
+		AppVersion: "1.21.0",
+	})
+
+	r, err := client.AppsV1().Releases("web").Get(ctx, "nginx", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Revision != "3" || r.Chart != "nginx-ingress-1.2.3" || r.Status != string(v1.ReleasePhaseDeployed) {
+		t.Errorf("unexpected release: %+v", r)
+	}
+
+	_, err = client.AppsV1().Releases("default").Get(ctx, "nginx", metav1.GetOptions{})
+	if !utilhelm.IsReleaseNotFound(err) {
+		t.Errorf("expected release not found error in other namespace, got %v", err)
+	}
+}
+
+func TestFakeClientsetLifecycle(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	releases := client.AppsV1().Releases("apps")
+
+	version := "1.0.0"
+	r, err := releases.Install(ctx, "redis", metav1.InstallOptions{
+		ChartReference: "bitnami/redis",
+		Version:        &version,
+		ValuesSets:     map[string]string{"auth.enabled": "false", "replicas": "2"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected install error: %v", err)
+	}
+	if r.Revision != "1" || r.Chart != "redis-1.0.0" {
+		t.Errorf("unexpected installed release: %+v", r)
+	}
+	if _, err = releases.Install(ctx, "redis", metav1.InstallOptions{ChartReference: "bitnami/redis"}); !utilhelm.IsAlreadyExists(err) {
+		t.Errorf("expected already exists error, got %v", err)
+	}
+
+	values, err := releases.GetValues(ctx, "redis", metav1.GetValuesOptions{})
+	if err != nil {
+		t.Fatalf("unexpected get values error: %v", err)
+	}
+	if fmt.Sprint(values) != "map[auth:map[enabled:false] replicas:2]" {
+		t.Errorf("unexpected values: %v", values)
+	}
+
+	version = "1.1.0"
+	if r, err = releases.Upgrade(ctx, "redis", metav1.UpgradeOptions{ChartReference: "bitnami/redis", Version: &version}); err != nil {
+		t.Fatalf("unexpected upgrade error: %v", err)
+	}
+	if r.Revision != "2" || r.Chart != "redis-1.1.0" {
+		t.Errorf("unexpected upgraded release: %+v", r)
+	}
+	if err = releases.Rollback(ctx, "redis", 0, metav1.RollbackOptions{}); err != nil {
+		t.Fatalf("unexpected rollback error: %v", err)
+	}
+
+	history, err := releases.History(ctx, "redis", metav1.HistoryOptions{})
+	if err != nil {
+		t.Fatalf("unexpected history error: %v", err)
+	}
+	expected := []struct {
+		status string
+		chart  string
+	}{
+		{"superseded", "redis-1.0.0"},
+		{"superseded", "redis-1.1.0"},
+		{"deployed", "redis-1.0.0"},
+	}
+	if len(history.Items) != len(expected) {
+		t.Fatalf("expected %d revisions, got %d", len(expected), len(history.Items))
+	}
+	for i, item := range history.Items {
+		if item.Revision != i+1 || item.Status != expected[i].status || item.Chart != expected[i].chart {
+			t.Errorf("unexpected revision %d: %+v", i+1, item)
+		}
+	}
+
+	resp, err := releases.Delete(ctx, "redis", metav1.DeleteOptions{KeepHistory: true})
+	if err != nil {
+		t.Fatalf("unexpected delete error: %v", err)
+	}
+	if resp.Release.Status != string(v1.ReleasePhaseUninstalled) {
+		t.Errorf("unexpected uninstalled release: %+v", resp.Release)
+	}
+	// the release is still got with its history kept
+	if r, err = releases.Get(ctx, "redis", metav1.GetOptions{}); err != nil || r.Status != string(v1.ReleasePhaseUninstalled) {
+		t.Errorf("expected the uninstalled release, got %+v, %v", r, err)
+	}
+	list, err := releases.List(ctx, metav1.ListOptions{Uninstalled: true})
+	if err != nil {
+		t.Fatalf("unexpected list error: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Revision != "3" {
+		t.Errorf("unexpected uninstalled releases: %+v", list.Items)
+	}
+
+	verbs := []string{"install", "install", "get", "upgrade", "rollback", "get", "delete", "get", "list"}
+	actions := client.Actions()
+	if len(actions) != len(verbs) {
+		t.Fatalf("expected %d actions, got %d", len(verbs), len(actions))
+	}
+	for i, action := range actions {
+		if !action.Matches(verbs[i], helmtesting.ReleasesResource) || action.GetNamespace() != "apps" {
+			t.Errorf("unexpected action %d: %v", i, action)
+		}
+	}
+}
+
+func TestFakeClientsetValues(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	releases := client.AppsV1().Releases("apps")
+
+	_, err := releases.Install(ctx, "redis", metav1.InstallOptions{
+		ChartReference: "bitnami/redis",
+		Values:         map[string]interface{}{"auth": map[string]interface{}{"enabled": false}, "replicas": 2},
+	})
+	if err != nil {
+		t.Fatalf("unexpected install error: %v", err)
+	}
+	_, err = releases.Upgrade(ctx, "redis", metav1.UpgradeOptions{
+		ChartReference: "bitnami/redis",
+		ReuseValues:    true,
+		Values:         map[string]interface{}{"replicas": 3},
+	})
+	if err != nil {
+		t.Fatalf("unexpected upgrade error: %v", err)
+	}
+	values, err := releases.GetValues(ctx, "redis", metav1.GetValuesOptions{})
+	if err != nil {
+		t.Fatalf("unexpected get values error: %v", err)
+	}
+	if fmt.Sprint(values) != "map[auth:map[enabled:false] replicas:3]" {
+		t.Errorf("unexpected values: %v", values)
+	}
+
+	// the values which can not be marshaled fail the action
+	invalid := map[string]interface{}{"replicas": make(chan int)}
+	if _, err = releases.Install(ctx, "nginx", metav1.InstallOptions{ChartReference: "bitnami/nginx", Values: invalid}); err == nil {
+		t.Errorf("expected install error, got nil")
+	}
+	if _, err = releases.Upgrade(ctx, "redis", metav1.UpgradeOptions{ChartReference: "bitnami/redis", Values: invalid}); err == nil {
+		t.Errorf("expected upgrade error, got nil")
+	}
+	if _, err = releases.Upgrade(ctx, "nginx", metav1.UpgradeOptions{ChartReference: "bitnami/nginx", Install: true, Values: invalid}); err == nil {
+		t.Errorf("expected upgrade install error, got nil")
+	}
+	if _, err = releases.Get(ctx, "nginx", metav1.GetOptions{}); !utilhelm.IsReleaseNotFound(err) {
+		t.Errorf("expected release not found error, got %v", err)
+	}
+}
+
+func TestFakeClientsetList(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset(
+		v1.Release{Name: "a", Namespace: "ns1"},
+		v1.Release{Name: "b", Namespace: "ns1", Status: "failed"},
+		v1.Release{Name: "c", Namespace: "ns2"},
+		v1.Release{Name: "d", Namespace: "ns2", Status: "pending-install"},
+	)
+
+	testCases := []struct {
+		name      string
+		namespace string
+		opts      metav1.ListOptions
+		expected  []string
+		continues string
+	}{
+		{name: "default states", namespace: "ns1", expected: []string{"a", "b"}},
+		{name: "all namespaces", expected: []string{"a", "b", "c"}},
+		{name: "pending", opts: metav1.ListOptions{Pending: true}, expected: []string{"d"}},
+		{name: "filter", opts: metav1.ListOptions{All: true, Filter: "^[cd]$"}, expected: []string{"c", "d"}},
+		{name: "first page", opts: metav1.ListOptions{All: true, Max: 2}, expected: []string{"a", "b"}, continues: "2"},
+		{name: "next page", opts: metav1.ListOptions{All: true, Max: 2, Continue: "2"}, expected: []string{"c", "d"}, continues: "4"},
+		{name: "reverse", opts: metav1.ListOptions{Reverse: true}, expected: []string{"c", "b", "a"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			list, err := client.AppsV1().Releases(tc.namespace).List(ctx, tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var names []string
+			for _, r := range list.Items {
+				names = append(names, r.Name)
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, names)
+			}
+			if list.Continue != tc.continues {
+				t.Errorf("expected continue %q, got %q", tc.continues, list.Continue)
+			}
+		})
+	}
+}
+
+func TestFakeClientsetReactor(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("install", "releases", func(action helmtesting.Action) (bool, interface{}, error) {
+		install := action.(helmtesting.InstallAction)
+		if install.GetInstallOptions().ChartReference == "broken/chart" {
+			return true, nil, &utilhelm.StatusError{Reason: utilhelm.StatusReasonInvalid, Message: "parse error"}
+		}
+		return false, nil, nil
+	})
+
+	_, err := client.AppsV1().Releases("default").Install(ctx, "broken", metav1.InstallOptions{ChartReference: "broken/chart"})
+	if !utilhelm.IsInvalid(err) {
+		t.Errorf("expected invalid error, got %v", err)
+	}
+	if _, err = client.AppsV1().Releases("default").Install(ctx, "", metav1.InstallOptions{ChartReference: "stable/mysql", GenerateName: true}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	list, err := client.AppsV1().Releases("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Name != "mysql-1" {
+		t.Errorf("unexpected releases: %+v", list.Items)
+	}
+}
+
+func TestFakeClientsetRepos(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	repos := client.AppsV1().Repos("")
+
+	if err := repos.Add(ctx, v1.Repo{Name: "bitnami", URL: "https://charts.bitnami.com/bitnami"}, metav1.RepoAddOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := repos.Update(ctx, "bitnami"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := repos.Remove(ctx, "stable"); !utilhelm.IsRepoNotFound(err) {
+		t.Errorf("expected repo not found error, got %v", err)
+	}
+
+	list, err := repos.List(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Name != "bitnami" {
+		t.Errorf("unexpected repos: %+v", list.Items)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	"github.com/caoyingjunz/client-helm/helm"
+	listersv1 "github.com/caoyingjunz/client-helm/listers/apps/v1"
+	"github.com/caoyingjunz/client-helm/pkg/watch"
+	"github.com/caoyingjunz/client-helm/tools/cache"
+)
+
+// TweakListOptionsFunc modifies the options of the list and the watch of an
+// informer.
+type TweakListOptionsFunc func(*metav1.ListOptions)
+
+// ReleaseInformer provides access to a shared informer and lister for
+// Releases.
+type ReleaseInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() listersv1.ReleaseLister
+}
+
+type releaseInformer struct {
+	client           helm.Interface
+	namespace        string
+	resyncPeriod     time.Duration
+	tweakListOptions TweakListOptionsFunc
+
+	once     sync.Once
+	informer cache.SharedIndexInformer
+}
+
+// New returns a ReleaseInformer of the releases in the namespace, or in all
+// namespaces if it is empty. The informer is created on first use and shared
+// by the callers of Informer and Lister.
+func New(client helm.Interface, namespace string, resyncPeriod time.Duration, tweakListOptions TweakListOptionsFunc) ReleaseInformer {
+	return &releaseInformer{
+		client:           client,
+		namespace:        namespace,
+		resyncPeriod:     resyncPeriod,
+		tweakListOptions: tweakListOptions,
+	}
+}
+
+// NewReleaseInformer constructs a new informer for Release type.
+// Always prefer using New to get a shared informer instead of getting an
+// independent one, since each informer lists the releases on its own.
+func NewReleaseInformer(client helm.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredReleaseInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredReleaseInformer constructs a new informer for Release type. The
+// releases in all states are informed unless tweakListOptions says otherwise,
+// the poll interval of the watch can be set by it as well.
+func NewFilteredReleaseInformer(client helm.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions TweakListOptionsFunc) cache.SharedIndexInformer {
+	tweak := func(opts *metav1.ListOptions) {
+		opts.All = true
+		if tweakListOptions != nil {
+			tweakListOptions(opts)
+		}
+	}
+
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(ctx context.Context, opts metav1.ListOptions) (*v1.ReleaseList, error) {
+				tweak(&opts)
+				return client.AppsV1().Releases(namespace).List(ctx, opts)
+			},
+			WatchFunc: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+				tweak(&opts)
+				return client.AppsV1().Releases(namespace).Watch(ctx, opts)
+			},
+		},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *releaseInformer) Informer() cache.SharedIndexInformer {
+	f.once.Do(func() {
+		f.informer = NewFilteredReleaseInformer(f.client, f.namespace, f.resyncPeriod,
+			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+	})
+
+	return f.informer
+}
+
+func (f *releaseInformer) Lister() listersv1.ReleaseLister {
+	return listersv1.NewReleaseLister(f.Informer().GetIndexer())
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	"github.com/caoyingjunz/client-helm/helm/fake"
+	utilhelm "github.com/caoyingjunz/client-helm/pkg/util/helm"
+	"github.com/caoyingjunz/client-helm/tools/cache"
+)
+
+// recorder records the events of the informer as "type name.revision".
+type recorder struct {
+	lock   sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(eventType string, obj interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	release := obj.(*v1.Release)
+	r.events = append(r.events, eventType+" "+release.Name+"."+release.Revision)
+}
+
+func (r *recorder) handler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { r.record("add", obj) },
+		UpdateFunc: func(oldObj, newObj interface{}) { r.record("update", newObj) },
+		DeleteFunc: func(obj interface{}) { r.record("delete", obj) },
+	}
+}
+
+func (r *recorder) waitFor(t *testing.T, count int) []string {
+	var events []string
+	err := wait.PollImmediate(time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		events = append([]string(nil), r.events...)
+		return len(events) >= count, nil
+	})
+	if err != nil {
+		t.Fatalf("timed out waiting for %d events, got %v", count, events)
+	}
+	return events
+}
+
+func TestReleaseInformer(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset(
+		v1.Release{Name: "nginx", Namespace: "web", Chart: "nginx-1.0.0"},
+		v1.Release{Name: "redis", Namespace: "db", Chart: "redis-1.0.0", Status: "failed"},
+	)
+
+	informer := New(client, "", 0, nil)
+	r := &recorder{}
+	informer.Informer().AddEventHandler(r.handler())
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go informer.Informer().Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, informer.Informer().HasSynced) {
+		t.Fatalf("failed to sync the informer")
+	}
+
+	lister := informer.Lister()
+	releases, err := lister.List(labels.Everything())
+	if err != nil || len(releases) != 2 {
+		t.Errorf("unexpected releases %v: %v", releases, err)
+	}
+	failed, err := lister.List(labels.SelectorFromSet(labels.Set{"status": "failed"}))
+	if err != nil || len(failed) != 1 || failed[0].Name != "redis" {
+		t.Errorf("unexpected failed releases %v: %v", failed, err)
+	}
+	if _, err = lister.Releases("db").Get("nginx"); !utilhelm.IsReleaseNotFound(err) {
+		t.Errorf("expected release not found error, got %v", err)
+	}
+
+	releaseClient := client.AppsV1().Releases("web")
+	if _, err = releaseClient.Upgrade(ctx, "nginx", metav1.UpgradeOptions{ChartReference: "bitnami/nginx"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = releaseClient.Install(ctx, "mysql", metav1.InstallOptions{ChartReference: "bitnami/mysql"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = client.AppsV1().Releases("db").Delete(ctx, "redis", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events := r.waitFor(t, 5)
+	expected := []string{"add nginx.1", "add redis.1", "update nginx.2", "add mysql.1", "delete redis.1"}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, events)
+			break
+		}
+	}
+
+	web, err := lister.Releases("web").List(labels.Everything())
+	if err != nil || len(web) != 2 {
+		t.Errorf("unexpected releases in web %v: %v", web, err)
+	}
+	nginx, err := lister.Releases("web").Get("nginx")
+	if err != nil || nginx.Revision != "2" {
+		t.Errorf("unexpected release %v: %v", nginx, err)
+	}
+
+	// a handler added late gets the cached releases
+	late := &recorder{}
+	informer.Informer().AddEventHandler(late.handler())
+	if events := late.waitFor(t, 2); len(events) != 2 {
+		t.Errorf("unexpected events of the late handler: %v", events)
+	}
+}
+
+func TestReleaseInformerHandlers(t *testing.T) {
+	client := fake.NewSimpleClientset(v1.Release{Name: "nginx", Namespace: "web", Chart: "nginx-1.0.0"})
+	informer := NewReleaseInformer(client, "", 0, nil)
+
+	// a slow handler does not delay the others
+	block := make(chan struct{})
+	defer close(block)
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { <-block },
+	})
+	// a handler may call back into the informer
+	inner := &recorder{}
+	r := &recorder{}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if len(informer.GetStore().List()) != 0 {
+				informer.AddEventHandler(inner.handler())
+			}
+			r.record("add", obj)
+		},
+	})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go informer.Run(stopCh)
+
+	if events := r.waitFor(t, 1); events[0] != "add nginx.1" {
+		t.Errorf("unexpected events: %v", events)
+	}
+	if events := inner.waitFor(t, 1); events[0] != "add nginx.1" {
+		t.Errorf("unexpected events of the inner handler: %v", events)
+	}
+}
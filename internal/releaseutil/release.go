@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package releaseutil
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+)
+
+// FromStatus converts the detail of a release to the release summary printed
+// by `helm list`.
+func FromStatus(rs *v1.ReleaseStatus) *v1.Release {
+	r := &v1.Release{
+		Name:      rs.Name,
+		Namespace: rs.Namespace,
+		Revision:  strconv.Itoa(rs.Version),
+	}
+	if rs.Info != nil {
+		r.Updated = rs.Info.LastDeployed.String()
+		r.Status = string(rs.Info.Status)
+	}
+	if rs.Chart != nil && rs.Chart.Metadata != nil {
+		r.Chart = fmt.Sprintf("%s-%s", rs.Chart.Metadata.Name, rs.Chart.Metadata.Version)
+		r.AppVersion = rs.Chart.Metadata.AppVersion
+	}
+
+	return r
+}
+
+// ListStates returns the states of the releases listed with the options, the
+// deployed and the failed releases are listed if no state is set, as
+// `helm list` does.
+func ListStates(opts metav1.ListOptions) map[v1.ReleasePhase]bool {
+	states := map[v1.ReleasePhase]bool{
+		v1.ReleasePhaseDeployed:        opts.All || opts.Deployed,
+		v1.ReleasePhaseFailed:          opts.All || opts.Failed,
+		v1.ReleasePhasePendingInstall:  opts.All || opts.Pending,
+		v1.ReleasePhasePendingUpgrade:  opts.All || opts.Pending,
+		v1.ReleasePhasePendingRollback: opts.All || opts.Pending,
+		v1.ReleasePhaseSuperseded:      opts.All || opts.Superseded,
+		v1.ReleasePhaseUninstalled:     opts.All || opts.Uninstalled,
+		v1.ReleasePhaseUninstalling:    opts.All || opts.Uninstalling,
+		v1.ReleasePhaseUnknown:         opts.All,
+	}
+	if !HasStates(opts) {
+		states[v1.ReleasePhaseDeployed] = true
+		states[v1.ReleasePhaseFailed] = true
+	}
+
+	return states
+}
+
+// HasStates returns true if any state filter is set in the options.
+func HasStates(opts metav1.ListOptions) bool {
+	return opts.All || opts.Deployed || opts.Failed || opts.Pending || opts.Superseded || opts.Uninstalled || opts.Uninstalling
+}
+
+// MergeValues returns the values overridden by the overrides recursively,
+// like the coalesced values printed by `helm get values --all`. The nested
+// maps are merged and the other values are replaced, neither the values nor
+// the overrides are changed.
+func MergeValues(values, overrides map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(values)+len(overrides))
+	for key, value := range values {
+		merged[key] = copyValue(value)
+	}
+	for key, value := range overrides {
+		if override, ok := value.(map[string]interface{}); ok {
+			if base, ok := merged[key].(map[string]interface{}); ok {
+				merged[key] = MergeValues(base, override)
+				continue
+			}
+		}
+		merged[key] = copyValue(value)
+	}
+
+	return merged
+}
+
+// copyValue deep copies the maps and the slices of a value.
+func copyValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			out[k] = copyValue(v)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, v := range value {
+			out[i] = copyValue(v)
+		}
+		return out
+	}
+
+	return value
+}
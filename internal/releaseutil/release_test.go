@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package releaseutil
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+)
+
+func TestMergeValues(t *testing.T) {
+	values := map[string]interface{}{
+		"image":    map[string]interface{}{"repository": "nginx", "tag": "1.20"},
+		"ports":    []interface{}{80},
+		"replicas": 1,
+	}
+	overrides := map[string]interface{}{
+		"image":    map[string]interface{}{"tag": "1.21"},
+		"ports":    []interface{}{8080},
+		"service":  map[string]interface{}{"type": "NodePort"},
+		"replicas": nil,
+	}
+
+	merged := MergeValues(values, overrides)
+	expected := "map[image:map[repository:nginx tag:1.21] ports:[8080] replicas:<nil> service:map[type:NodePort]]"
+	if fmt.Sprint(merged) != expected {
+		t.Errorf("expected %s, got %v", expected, merged)
+	}
+
+	// the inputs are not changed by the merge or the merged values
+	merged["image"].(map[string]interface{})["tag"] = "latest"
+	merged["service"].(map[string]interface{})["type"] = "ClusterIP"
+	if fmt.Sprint(values["image"]) != "map[repository:nginx tag:1.20]" || fmt.Sprint(overrides["service"]) != "map[type:NodePort]" {
+		t.Errorf("unexpected change of the inputs: %v, %v", values, overrides)
+	}
+}
+
+func TestListStates(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     metav1.ListOptions
+		expected []v1.ReleasePhase
+	}{
+		{name: "default", expected: []v1.ReleasePhase{v1.ReleasePhaseDeployed, v1.ReleasePhaseFailed}},
+		{name: "pending", opts: metav1.ListOptions{Pending: true}, expected: []v1.ReleasePhase{
+			v1.ReleasePhasePendingInstall, v1.ReleasePhasePendingUpgrade, v1.ReleasePhasePendingRollback}},
+		{name: "uninstalled", opts: metav1.ListOptions{Uninstalled: true}, expected: []v1.ReleasePhase{v1.ReleasePhaseUninstalled}},
+		{name: "all", opts: metav1.ListOptions{All: true}, expected: []v1.ReleasePhase{
+			v1.ReleasePhaseDeployed, v1.ReleasePhaseFailed, v1.ReleasePhasePendingInstall, v1.ReleasePhasePendingUpgrade,
+			v1.ReleasePhasePendingRollback, v1.ReleasePhaseSuperseded, v1.ReleasePhaseUninstalled, v1.ReleasePhaseUninstalling,
+			v1.ReleasePhaseUnknown}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			states := ListStates(tc.opts)
+			count := 0
+			for _, listed := range states {
+				if listed {
+					count++
+				}
+			}
+			if count != len(tc.expected) {
+				t.Errorf("expected %d states, got %v", len(tc.expected), states)
+			}
+			for _, state := range tc.expected {
+				if !states[state] {
+					t.Errorf("expected %s to be listed, got %v", state, states)
+				}
+			}
+		})
+	}
+}
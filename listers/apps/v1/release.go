@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	utilhelm "github.com/caoyingjunz/client-helm/pkg/util/helm"
+	"github.com/caoyingjunz/client-helm/tools/cache"
+)
+
+// ReleaseLister helps list Releases.
+// All objects returned here must be treated as read-only.
+type ReleaseLister interface {
+	// List lists all Releases in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1.Release, err error)
+	// Releases returns an object that can list and get Releases.
+	Releases(namespace string) ReleaseNamespaceLister
+}
+
+// releaseLister implements the ReleaseLister interface.
+type releaseLister struct {
+	indexer cache.Indexer
+}
+
+// NewReleaseLister returns a new ReleaseLister.
+func NewReleaseLister(indexer cache.Indexer) ReleaseLister {
+	return &releaseLister{indexer: indexer}
+}
+
+// List lists all Releases in the indexer, the selector is matched against the
+// labels helm sets on the release records: name, status and version.
+func (s *releaseLister) List(selector labels.Selector) (ret []*v1.Release, err error) {
+	return filter(s.indexer.List(), selector), nil
+}
+
+// Releases returns an object that can list and get Releases.
+func (s *releaseLister) Releases(namespace string) ReleaseNamespaceLister {
+	return releaseNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// ReleaseNamespaceLister helps list and get Releases.
+// All objects returned here must be treated as read-only.
+type ReleaseNamespaceLister interface {
+	// List lists all Releases in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1.Release, err error)
+	// Get retrieves the Release from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1.Release, error)
+}
+
+// releaseNamespaceLister implements the ReleaseNamespaceLister
+// interface.
+type releaseNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all Releases in the indexer for a given namespace.
+func (s releaseNamespaceLister) List(selector labels.Selector) (ret []*v1.Release, err error) {
+	objs, err := s.indexer.ByIndex(cache.NamespaceIndex, s.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return filter(objs, selector), nil
+}
+
+// Get retrieves the Release from the indexer for a given namespace and name.
+func (s releaseNamespaceLister) Get(name string) (*v1.Release, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, utilhelm.NewReleaseNotFound(name)
+	}
+
+	return obj.(*v1.Release), nil
+}
+
+func filter(objs []interface{}, selector labels.Selector) []*v1.Release {
+	var ret []*v1.Release
+	for _, obj := range objs {
+		r := obj.(*v1.Release)
+		if selector != nil && !selector.Matches(labels.Set{"name": r.Name, "status": r.Status, "version": r.Revision}) {
+			continue
+		}
+		ret = append(ret, r)
+	}
+
+	return ret
+}
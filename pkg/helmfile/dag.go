@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmfile
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// runInOrder calls fn for each release once the releases it needs are done,
+// or once the releases which need it are done if reverse is set. At most
+// concurrency releases are processed at a time, zero means no limit. The
+// releases depending on a failed release are skipped.
+func runInOrder(ctx context.Context, releases []Release, reverse bool, concurrency int, fn func(context.Context, *Release) error) error {
+	// deps is the releases to wait for before each release
+	deps := make(map[string][]string, len(releases))
+	for i := range releases {
+		r := &releases[i]
+		if _, ok := deps[r.Key()]; !ok {
+			deps[r.Key()] = nil
+		}
+		for _, need := range r.needKeys() {
+			if reverse {
+				deps[need] = append(deps[need], r.Key())
+			} else {
+				deps[r.Key()] = append(deps[r.Key()], need)
+			}
+		}
+	}
+
+	done := make(map[string]chan struct{}, len(releases))
+	for key := range deps {
+		done[key] = make(chan struct{})
+	}
+
+	var sem chan struct{}
+	if concurrency > 0 {
+		sem = make(chan struct{}, concurrency)
+	}
+
+	var (
+		lock   sync.Mutex
+		errs   = map[string]error{}
+		failed = func(key string) bool {
+			lock.Lock()
+			defer lock.Unlock()
+			_, ok := errs[key]
+			return ok
+		}
+		wg sync.WaitGroup
+	)
+	for i := range releases {
+		r := &releases[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done[r.Key()])
+
+			err := func() error {
+				for _, dep := range deps[r.Key()] {
+					select {
+					case <-done[dep]:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+					if failed(dep) {
+						return fmt.Errorf("skipped, release %s failed", dep)
+					}
+				}
+				if sem != nil {
+					select {
+					case sem <- struct{}{}:
+						defer func() { <-sem }()
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+				return fn(ctx, r)
+			}()
+			if err != nil {
+				lock.Lock()
+				errs[r.Key()] = err
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	keys := make([]string, 0, len(errs))
+	for key := range errs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	aggregate := make([]error, 0, len(keys))
+	for _, key := range keys {
+		aggregate = append(aggregate, fmt.Errorf("release %s: %v", key, errs[key]))
+	}
+
+	return utilerrors.NewAggregate(aggregate)
+}
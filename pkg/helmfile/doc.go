@@ -0,0 +1,22 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package helmfile syncs a set of releases declared in a YAML spec, in the
+// spirit of helmfile. The repositories are added first, then the releases are
+// installed or upgraded in the order of their needs, with a bounded number of
+// releases processed at a time. The changes can be previewed by Diff, and the
+// releases uninstalled by Destroy.
+package helmfile
@@ -0,0 +1,335 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmfile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	"github.com/caoyingjunz/client-helm/controllers/release"
+	"github.com/caoyingjunz/client-helm/helm"
+	utilhelm "github.com/caoyingjunz/client-helm/pkg/util/helm"
+)
+
+const defaultWaitTimeout = 5 * time.Minute
+
+// ActionDelete means the release is uninstalled, it extends the actions of
+// the release reconciler.
+const ActionDelete release.Action = "Delete"
+
+// Options are the options of the Engine.
+type Options struct {
+	// Concurrency is the number of releases processed at a time, zero means
+	// no limit.
+	Concurrency int
+	// WaitTimeout is the longest Sync waits for the operation in progress on
+	// a release which has no timeout, 5 minutes by default.
+	WaitTimeout time.Duration
+	// Out receives the progress of the operations, nothing is written if nil.
+	Out io.Writer
+}
+
+// Change is a change Sync would make to a release.
+type Change struct {
+	// Release is the namespace/name of the release.
+	Release string
+	Action  release.Action
+	Reason  string
+	// Diff is the line diff of the values of the release, in YAML.
+	Diff string
+}
+
+// Engine syncs the releases of a spec.
+type Engine struct {
+	client     helm.Interface
+	options    Options
+	reconciler *release.Reconciler
+
+	lock sync.Mutex
+}
+
+// NewEngine returns an Engine, the failed releases are upgraded again rather
+// than rolled back, as the spec is applied once.
+func NewEngine(client helm.Interface, options Options) *Engine {
+	if options.Out == nil {
+		options.Out = ioutil.Discard
+	}
+	if options.WaitTimeout <= 0 {
+		options.WaitTimeout = defaultWaitTimeout
+	}
+
+	return &Engine{
+		client:     client,
+		options:    options,
+		reconciler: release.NewReconciler(client, release.Options{DisableRollback: true}),
+	}
+}
+
+// Sync adds and updates the repositories, then installs or upgrades the
+// releases in the order of their needs, and uninstalls the releases which
+// are not installed in the spec. A release with an operation in progress is
+// waited for until its timeout, or the WaitTimeout of the engine, after which
+// it fails and the releases which need it are skipped.
+func (e *Engine) Sync(ctx context.Context, spec *Spec) error {
+	if err := e.addRepos(ctx, spec.Repositories); err != nil {
+		return err
+	}
+
+	return runInOrder(ctx, spec.Releases, false, e.options.Concurrency, func(ctx context.Context, r *Release) error {
+		if !r.IsInstalled() {
+			return e.delete(ctx, r)
+		}
+
+		rs, err := r.releaseSpec()
+		if err != nil {
+			return err
+		}
+		timeout := e.options.WaitTimeout
+		if rs.Timeout > 0 {
+			timeout = rs.Timeout
+		}
+		deadline := time.Now().Add(timeout)
+		for {
+			result, err := e.reconciler.Reconcile(ctx, rs)
+			if err != nil {
+				e.printf("%s: %v\n", r.Key(), err)
+				return err
+			}
+			status, _ := e.reconciler.Status(r.Namespace, r.Name)
+			if status.LastAction != release.ActionWait {
+				e.printf("%s: %s, revision %s\n", r.Key(), status.LastAction, status.Revision)
+				return nil
+			}
+
+			pending := "operation in progress"
+			if condition := status.GetCondition(release.ConditionReady); condition != nil {
+				pending = condition.Message
+			}
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				err = fmt.Errorf("%s after waiting %v", pending, timeout)
+				e.printf("%s: %v\n", r.Key(), err)
+				return err
+			}
+			e.printf("%s: waiting, %s\n", r.Key(), pending)
+
+			delay := result.RequeueAfter
+			if delay > remaining {
+				delay = remaining
+			}
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+}
+
+// Diff returns the changes Sync would make to the releases, the releases
+// which are up to date are left out.
+func (e *Engine) Diff(ctx context.Context, spec *Spec) ([]Change, error) {
+	changes := make([]Change, len(spec.Releases))
+	err := runInOrder(ctx, spec.Releases, false, e.options.Concurrency, func(ctx context.Context, r *Release) error {
+		change, err := e.diff(ctx, r)
+		if err != nil {
+			return err
+		}
+		for i := range spec.Releases {
+			if spec.Releases[i].Key() == r.Key() {
+				changes[i] = change
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []Change
+	for _, change := range changes {
+		if change.Action != release.ActionNone {
+			diffs = append(diffs, change)
+		}
+	}
+
+	return diffs, nil
+}
+
+func (e *Engine) diff(ctx context.Context, r *Release) (Change, error) {
+	releases := e.client.AppsV1().Releases(r.Namespace)
+	change := Change{Release: r.Key(), Action: release.ActionNone}
+
+	if !r.IsInstalled() {
+		current, err := releases.Get(ctx, r.Name, metav1.GetOptions{})
+		if err != nil {
+			if utilhelm.IsReleaseNotFound(err) {
+				return change, nil
+			}
+			return change, err
+		}
+		// the history of an uninstalled release may be kept
+		if current.Status != string(v1.ReleasePhaseUninstalled) {
+			change.Action, change.Reason = ActionDelete, "release is not installed in the spec"
+		}
+		return change, nil
+	}
+
+	rs, err := r.releaseSpec()
+	if err != nil {
+		return change, err
+	}
+	p, err := e.reconciler.Plan(ctx, rs)
+	if err != nil {
+		return change, err
+	}
+	change.Action, change.Reason = p.Action, p.Reason
+
+	var current map[string]interface{}
+	switch p.Action {
+	case release.ActionNone:
+		return change, nil
+	case release.ActionUpgrade, release.ActionRollback:
+		if current, err = releases.GetValues(ctx, r.Name, metav1.GetValuesOptions{}); err != nil {
+			return change, err
+		}
+	}
+	if change.Diff, err = diffValues(current, rs.Values); err != nil {
+		return change, err
+	}
+
+	return change, nil
+}
+
+// Destroy uninstalls the releases, the releases are uninstalled before the
+// releases they need.
+func (e *Engine) Destroy(ctx context.Context, spec *Spec) error {
+	return runInOrder(ctx, spec.Releases, true, e.options.Concurrency, e.delete)
+}
+
+// delete uninstalls the release, a release which is not found is ignored.
+func (e *Engine) delete(ctx context.Context, r *Release) error {
+	_, err := e.client.AppsV1().Releases(r.Namespace).Delete(ctx, r.Name, metav1.DeleteOptions{
+		Wait:    r.Wait,
+		Timeout: time.Duration(r.Timeout) * time.Second,
+	})
+	if err != nil {
+		if utilhelm.IsReleaseNotFound(err) {
+			return nil
+		}
+		e.printf("%s: %v\n", r.Key(), err)
+		return err
+	}
+
+	e.printf("%s: %s\n", r.Key(), ActionDelete)
+	return nil
+}
+
+// addRepos adds the repositories and updates their indexes.
+func (e *Engine) addRepos(ctx context.Context, repositories []Repository) error {
+	if len(repositories) == 0 {
+		return nil
+	}
+
+	repos := e.client.AppsV1().Repos("")
+	names := make([]string, 0, len(repositories))
+	for i := range repositories {
+		repo := &repositories[i]
+		opts := repo.repoAddOptions()
+		opts.ForceUpdate = true
+		if err := repos.Add(ctx, v1.Repo{Name: repo.Name, URL: repo.URL}, opts); err != nil {
+			return fmt.Errorf("add repository %s failed %v", repo.Name, err)
+		}
+		names = append(names, repo.Name)
+	}
+	if err := repos.Update(ctx, names...); err != nil {
+		return fmt.Errorf("update repositories failed %v", err)
+	}
+	e.printf("repositories %s added\n", strings.Join(names, ", "))
+
+	return nil
+}
+
+func (e *Engine) printf(format string, a ...interface{}) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	fmt.Fprintf(e.options.Out, format, a...)
+}
+
+// diffValues returns the line diff of the values in YAML, the removed lines
+// start with "-" and the added lines with "+".
+func diffValues(current, desired map[string]interface{}) (string, error) {
+	var a, b []string
+	for _, v := range []struct {
+		values map[string]interface{}
+		lines  *[]string
+	}{{current, &a}, {desired, &b}} {
+		if len(v.values) == 0 {
+			continue
+		}
+		data, err := yaml.Marshal(v.values)
+		if err != nil {
+			return "", fmt.Errorf("marshal values failed %v", err)
+		}
+		*v.lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+
+	// lcs[i][j] is the length of the longest common lines of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			diff.WriteString("  " + a[i] + "\n")
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			diff.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			diff.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+
+	return diff.String(), nil
+}
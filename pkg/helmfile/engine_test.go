@@ -0,0 +1,394 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmfile
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	"github.com/caoyingjunz/client-helm/controllers/release"
+	"github.com/caoyingjunz/client-helm/helm/fake"
+	helmtesting "github.com/caoyingjunz/client-helm/testing"
+)
+
+const testSpec = `
+repositories:
+- name: bitnami
+  url: https://charts.bitnami.com/bitnami
+releases:
+- name: wordpress
+  namespace: web
+  chart: bitnami/wordpress
+  values:
+  - values/wordpress.yaml
+  - image:
+      tag: "6.0"
+  needs:
+  - db/mysql
+  - cache
+- name: cache
+  namespace: web
+  chart: bitnami/redis
+- name: mysql
+  namespace: db
+  chart: bitnami/mysql
+  version: 8.8.8
+`
+
+func loadSpec(t *testing.T, data string) *Spec {
+	dir, err := ioutil.TempDir("", "helmfile")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	values := "replicaCount: 2\nimage:\n  repository: bitnami/wordpress\n  tag: \"5.9\"\n"
+	if err = os.MkdirAll(filepath.Join(dir, "values"), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "values", "wordpress.yaml"), []byte(values), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	path := filepath.Join(dir, "helmfile.yaml")
+	if err = ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spec, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return spec
+}
+
+func TestParse(t *testing.T) {
+	spec := loadSpec(t, testSpec)
+	rs, err := spec.Releases[0].releaseSpec()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(rs.Values) != "map[image:map[repository:bitnami/wordpress tag:6.0] replicaCount:2]" || !rs.CreateNamespace {
+		t.Errorf("unexpected release spec: %+v", rs)
+	}
+	if spec.Releases[1].Key() != "web/cache" {
+		t.Errorf("unexpected release key %s", spec.Releases[1].Key())
+	}
+
+	testCases := []struct {
+		name string
+		data string
+	}{
+		{name: "unknown field", data: "releases:\n- name: a\n  chart: c\n  foo: bar\n"},
+		{name: "missing chart", data: "releases:\n- name: a\n"},
+		{name: "duplicated", data: "releases:\n- name: a\n  chart: c\n- name: a\n  chart: c\n"},
+		{name: "unknown need", data: "releases:\n- name: a\n  chart: c\n  needs: [b]\n"},
+		{name: "need not installed", data: "releases:\n- name: a\n  chart: c\n  needs: [b]\n- name: b\n  installed: false\n"},
+		{name: "cycle", data: "releases:\n- name: a\n  chart: c\n  needs: [b]\n- name: b\n  chart: c\n  needs: [default/a]\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tc.data), "."); err == nil {
+				t.Errorf("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.TODO()
+	spec := loadSpec(t, testSpec)
+	client := fake.NewSimpleClientset()
+
+	var (
+		lock  sync.Mutex
+		order []string
+	)
+	client.PrependReactor("*", "releases", func(action helmtesting.Action) (bool, interface{}, error) {
+		if action.GetVerb() == helmtesting.VerbInstall || action.GetVerb() == helmtesting.VerbDelete {
+			lock.Lock()
+			order = append(order, fmt.Sprintf("%s %s/%s", action.GetVerb(), action.GetNamespace(), action.(interface{ GetName() string }).GetName()))
+			lock.Unlock()
+		}
+		return false, nil, nil
+	})
+
+	engine := NewEngine(client, Options{Concurrency: 1})
+	if err := engine.Sync(ctx, spec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// wordpress is installed after the releases it needs
+	if len(order) != 3 || order[2] != "install web/wordpress" {
+		t.Errorf("unexpected order: %v", order)
+	}
+	repos, err := client.AppsV1().Repos("").List(ctx)
+	if err != nil || len(repos.Items) != 1 || repos.Items[0].Name != "bitnami" {
+		t.Errorf("unexpected repositories: %+v, %v", repos, err)
+	}
+
+	changes, err := engine.Diff(ctx, spec)
+	if err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes, got %+v, %v", changes, err)
+	}
+
+	spec.Releases[0].Values = append(spec.Releases[0].Values, map[string]interface{}{"replicaCount": 3})
+	notInstalled := false
+	spec.Releases[1].Installed = &notInstalled
+	changes, err = engine.Diff(ctx, spec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 2 || changes[0].Action != release.ActionUpgrade || changes[1].Action != ActionDelete {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	if !strings.Contains(changes[0].Diff, "- replicaCount: 2\n+ replicaCount: 3\n") {
+		t.Errorf("unexpected diff:\n%s", changes[0].Diff)
+	}
+
+	order = nil
+	if err = engine.Sync(ctx, spec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r, err := client.AppsV1().Releases("web").Get(ctx, "wordpress", metav1.GetOptions{})
+	if err != nil || r.Revision != "2" {
+		t.Errorf("unexpected release: %+v, %v", r, err)
+	}
+	if fmt.Sprint(order) != "[delete web/cache]" {
+		t.Errorf("unexpected order: %v", order)
+	}
+
+	order = nil
+	if err = engine.Destroy(ctx, spec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// mysql is uninstalled after wordpress which needs it
+	index := map[string]int{}
+	for i, action := range order {
+		index[action] = i
+	}
+	if len(order) != 3 || index["delete web/wordpress"] > index["delete db/mysql"] {
+		t.Errorf("unexpected order: %v", order)
+	}
+	list, err := client.AppsV1().Releases("").List(ctx, metav1.ListOptions{All: true})
+	if err != nil || len(list.Items) != 0 {
+		t.Errorf("expected no releases, got %+v, %v", list, err)
+	}
+}
+
+func TestSyncFailure(t *testing.T) {
+	ctx := context.TODO()
+	spec := loadSpec(t, testSpec)
+	client := fake.NewSimpleClientset(v1.Release{Name: "cache", Namespace: "web", Chart: "redis-1.0.0"})
+	client.PrependReactor("install", "releases", func(action helmtesting.Action) (bool, interface{}, error) {
+		if action.GetNamespace() == "db" {
+			return true, nil, fmt.Errorf("chart not found")
+		}
+		return false, nil, nil
+	})
+
+	err := NewEngine(client, Options{}).Sync(ctx, spec)
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	for _, msg := range []string{"release db/mysql: Install release mysql failed chart not found", "release web/wordpress: skipped, release db/mysql failed"} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected %q in error, got %v", msg, err)
+		}
+	}
+	if _, err = client.AppsV1().Releases("web").Get(ctx, "wordpress", metav1.GetOptions{}); err == nil {
+		t.Errorf("expected wordpress not installed")
+	}
+}
+
+func TestSyncWait(t *testing.T) {
+	spec := loadSpec(t, testSpec)
+	client := fake.NewSimpleClientset(v1.Release{Name: "mysql", Namespace: "db", Chart: "mysql-8.8.8", Status: string(v1.ReleasePhasePendingInstall)})
+
+	// the wait is bounded by the timeout of the engine
+	start := time.Now()
+	err := NewEngine(client, Options{WaitTimeout: 50 * time.Millisecond}).Sync(context.TODO(), spec)
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	for _, msg := range []string{"release db/mysql: release is pending-install after waiting 50ms", "release web/wordpress: skipped, release db/mysql failed"} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected %q in error, got %v", msg, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the wait to stop after the timeout, waited %v", elapsed)
+	}
+
+	// the timeout of the release takes precedence
+	spec.Releases[2].Timeout = 1
+	start = time.Now()
+	if err = NewEngine(client, Options{WaitTimeout: time.Hour}).Sync(context.TODO(), spec); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "release db/mysql: release is pending-install after waiting 1s") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the wait to stop after the release timeout, waited %v", elapsed)
+	}
+}
+
+func TestSyncCanceled(t *testing.T) {
+	spec := loadSpec(t, testSpec)
+	client := fake.NewSimpleClientset(v1.Release{Name: "mysql", Namespace: "db", Chart: "mysql-8.8.8", Status: string(v1.ReleasePhasePendingUpgrade)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	err := NewEngine(client, Options{WaitTimeout: time.Hour}).Sync(ctx, spec)
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "release db/mysql: "+context.Canceled.Error()) {
+		t.Errorf("expected the wait canceled, got %v", err)
+	}
+	if _, err = client.AppsV1().Releases("web").Get(context.TODO(), "wordpress", metav1.GetOptions{}); err == nil {
+		t.Errorf("expected wordpress not installed")
+	}
+}
+
+func TestRunInOrderConcurrency(t *testing.T) {
+	var releases []Release
+	for i := 0; i < 6; i++ {
+		releases = append(releases, Release{Name: fmt.Sprintf("r%d", i), Namespace: defaultNamespace, Chart: "c"})
+	}
+
+	for _, concurrency := range []int{1, 2, 0} {
+		t.Run(fmt.Sprint(concurrency), func(t *testing.T) {
+			var (
+				lock          sync.Mutex
+				running, peak int
+			)
+			err := runInOrder(context.TODO(), releases, false, concurrency, func(ctx context.Context, r *Release) error {
+				lock.Lock()
+				running++
+				if running > peak {
+					peak = running
+				}
+				lock.Unlock()
+
+				time.Sleep(20 * time.Millisecond)
+
+				lock.Lock()
+				running--
+				lock.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			expected := concurrency
+			if concurrency == 0 {
+				expected = len(releases)
+			}
+			if peak != expected {
+				t.Errorf("expected %d releases processed at a time, got %d", expected, peak)
+			}
+		})
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	testCases := []struct {
+		name     string
+		current  map[string]interface{}
+		desired  map[string]interface{}
+		expected string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:     "added",
+			desired:  map[string]interface{}{"replicas": 2},
+			expected: "+ replicas: 2\n",
+		},
+		{
+			name:     "removed",
+			current:  map[string]interface{}{"replicas": 2},
+			desired:  map[string]interface{}{},
+			expected: "- replicas: 2\n",
+		},
+		{
+			name:     "unchanged",
+			current:  map[string]interface{}{"replicas": 2},
+			desired:  map[string]interface{}{"replicas": 2},
+			expected: "  replicas: 2\n",
+		},
+		{
+			name:     "changed",
+			current:  map[string]interface{}{"image": map[string]interface{}{"repository": "nginx", "tag": "1.20"}, "replicas": 2},
+			desired:  map[string]interface{}{"image": map[string]interface{}{"repository": "nginx", "tag": "1.21"}, "replicas": 2, "service": "ClusterIP"},
+			expected: "  image:\n    repository: nginx\n-   tag: \"1.20\"\n+   tag: \"1.21\"\n  replicas: 2\n+ service: ClusterIP\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			diff, err := diffValues(tc.current, tc.desired)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff != tc.expected {
+				t.Errorf("expected diff:\n%s\ngot:\n%s", tc.expected, diff)
+			}
+		})
+	}
+
+	if _, err := diffValues(nil, map[string]interface{}{"replicas": make(chan int)}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestDiffNotInstalled(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset(
+		v1.Release{Name: "mysql", Namespace: "db", Chart: "mysql-8.8.8", Status: string(v1.ReleasePhasePendingUpgrade)},
+		v1.Release{Name: "redis", Namespace: "web", Chart: "redis-1.0.0"},
+	)
+	if _, err := client.AppsV1().Releases("web").Delete(ctx, "redis", metav1.DeleteOptions{KeepHistory: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spec, err := Parse([]byte("releases:\n- name: mysql\n  namespace: db\n  installed: false\n- name: redis\n  namespace: web\n  installed: false\n"), ".")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	changes, err := NewEngine(client, Options{}).Diff(ctx, spec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the pending release is deleted, the uninstalled one is left alone
+	if len(changes) != 1 || changes[0].Release != "db/mysql" || changes[0].Action != ActionDelete {
+		t.Errorf("unexpected changes: %+v", changes)
+	}
+}
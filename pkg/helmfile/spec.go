@@ -0,0 +1,283 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmfile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	"github.com/caoyingjunz/client-helm/controllers/release"
+	"github.com/caoyingjunz/client-helm/internal/releaseutil"
+)
+
+const defaultNamespace = "default"
+
+// Spec declares the chart repositories and the releases to sync, e.g.
+//
+//	repositories:
+//	- name: bitnami
+//	  url: https://charts.bitnami.com/bitnami
+//	releases:
+//	- name: mysql
+//	  namespace: db
+//	  chart: bitnami/mysql
+//	  version: 8.8.8
+//	- name: wordpress
+//	  namespace: web
+//	  chart: bitnami/wordpress
+//	  values:
+//	  - values/wordpress.yaml
+//	  - replicaCount: 2
+//	  needs:
+//	  - db/mysql
+type Spec struct {
+	Repositories []Repository `json:"repositories,omitempty"`
+	Releases     []Release    `json:"releases,omitempty"`
+}
+
+// Repository is a chart repository, the username and the password are
+// expanded from the environment variables, e.g. ${REPO_PASSWORD}.
+type Repository struct {
+	Name                  string `json:"name"`
+	URL                   string `json:"url"`
+	Username              string `json:"username,omitempty"`
+	Password              string `json:"password,omitempty"`
+	CAFile                string `json:"caFile,omitempty"`
+	CertFile              string `json:"certFile,omitempty"`
+	KeyFile               string `json:"keyFile,omitempty"`
+	InsecureSkipTLSVerify bool   `json:"insecureSkipTLSVerify,omitempty"`
+}
+
+// Release is the desired state of a release.
+type Release struct {
+	Name string `json:"name"`
+	// Namespace is the namespace of the release, "default" if empty.
+	Namespace string `json:"namespace,omitempty"`
+	// Chart is the chart reference, the local charts are relative to the spec.
+	Chart string `json:"chart"`
+	// Version is the version of the chart, the latest version is installed if
+	// it is empty.
+	Version string `json:"version,omitempty"`
+	// Values are the paths of the values files relative to the spec, or the
+	// inline values, they are merged in order.
+	Values []interface{} `json:"values,omitempty"`
+	// Needs are the releases to sync before this one, as namespace/name, or
+	// as name for the releases in the same namespace.
+	Needs []string `json:"needs,omitempty"`
+
+	// Installed is false if the release must be uninstalled, true by default.
+	Installed *bool `json:"installed,omitempty"`
+	// CreateNamespace creates the namespace of the release, true by default.
+	CreateNamespace *bool `json:"createNamespace,omitempty"`
+	// Wait waits until the resources are ready.
+	Wait bool `json:"wait,omitempty"`
+	// Timeout is the timeout of the operations in seconds, zero means the
+	// helm default.
+	Timeout int `json:"timeout,omitempty"`
+
+	// baseDir is the directory of the spec.
+	baseDir string
+}
+
+// Load reads the spec from the file.
+func Load(path string) (*Spec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data, filepath.Dir(path))
+}
+
+// Parse parses the spec, the values files and the local charts are relative
+// to the baseDir.
+func Parse(data []byte, baseDir string) (*Spec, error) {
+	var spec Spec
+	if err := yaml.UnmarshalStrict(data, &spec); err != nil {
+		return nil, fmt.Errorf("unmarshal to helmfile spec failed %v", err)
+	}
+
+	for i := range spec.Repositories {
+		repo := &spec.Repositories[i]
+		repo.Username = os.ExpandEnv(repo.Username)
+		repo.Password = os.ExpandEnv(repo.Password)
+	}
+	for i := range spec.Releases {
+		r := &spec.Releases[i]
+		if len(r.Namespace) == 0 {
+			r.Namespace = defaultNamespace
+		}
+		r.baseDir = baseDir
+	}
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+}
+
+// Key returns the namespace/name of the release.
+func (r *Release) Key() string {
+	return r.Namespace + "/" + r.Name
+}
+
+// IsInstalled returns false if the release must be uninstalled.
+func (r *Release) IsInstalled() bool {
+	return r.Installed == nil || *r.Installed
+}
+
+// needKeys returns the keys of the releases needed by this one.
+func (r *Release) needKeys() []string {
+	keys := make([]string, 0, len(r.Needs))
+	for _, need := range r.Needs {
+		if !strings.Contains(need, "/") {
+			need = r.Namespace + "/" + need
+		}
+		keys = append(keys, need)
+	}
+
+	return keys
+}
+
+// releaseSpec returns the spec of the release for the reconciler, the values
+// files are read and merged with the inline values.
+func (r *Release) releaseSpec() (release.Spec, error) {
+	values := map[string]interface{}{}
+	for _, v := range r.Values {
+		switch v := v.(type) {
+		case string:
+			path := v
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(r.baseDir, path)
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return release.Spec{}, fmt.Errorf("read values of release %s failed %v", r.Key(), err)
+			}
+			var fileValues map[string]interface{}
+			if err = yaml.Unmarshal(data, &fileValues); err != nil {
+				return release.Spec{}, fmt.Errorf("unmarshal values file %s failed %v", v, err)
+			}
+			values = releaseutil.MergeValues(values, fileValues)
+		case map[string]interface{}:
+			values = releaseutil.MergeValues(values, v)
+		default:
+			return release.Spec{}, fmt.Errorf("invalid values of release %s: %v", r.Key(), v)
+		}
+	}
+
+	chart := r.Chart
+	if strings.HasPrefix(chart, "./") || strings.HasPrefix(chart, "../") {
+		chart = filepath.Join(r.baseDir, chart)
+	}
+
+	return release.Spec{
+		Name:            r.Name,
+		Namespace:       r.Namespace,
+		Chart:           chart,
+		Version:         r.Version,
+		Values:          values,
+		CreateNamespace: r.CreateNamespace == nil || *r.CreateNamespace,
+		Wait:            r.Wait,
+		Timeout:         time.Duration(r.Timeout) * time.Second,
+	}, nil
+}
+
+// repoAddOptions returns the options to add the repository.
+func (r *Repository) repoAddOptions() metav1.RepoAddOptions {
+	return metav1.RepoAddOptions{
+		Username:              r.Username,
+		Password:              r.Password,
+		CAFile:                r.CAFile,
+		CertFile:              r.CertFile,
+		KeyFile:               r.KeyFile,
+		InsecureSkipTLSVerify: r.InsecureSkipTLSVerify,
+	}
+}
+
+// validate checks the names, the needs, that the installed releases only need
+// installed releases and that the needs have no cycle.
+func (s *Spec) validate() error {
+	for _, repo := range s.Repositories {
+		if len(repo.Name) == 0 || len(repo.URL) == 0 {
+			return fmt.Errorf("repository name and url are required")
+		}
+	}
+
+	releases := make(map[string]*Release, len(s.Releases))
+	for i := range s.Releases {
+		r := &s.Releases[i]
+		if len(r.Name) == 0 {
+			return fmt.Errorf("release name is required")
+		}
+		if len(r.Chart) == 0 && r.IsInstalled() {
+			return fmt.Errorf("chart of release %s is required", r.Key())
+		}
+		if _, ok := releases[r.Key()]; ok {
+			return fmt.Errorf("release %s is duplicated", r.Key())
+		}
+		releases[r.Key()] = r
+	}
+	for _, r := range releases {
+		for _, need := range r.needKeys() {
+			needed, ok := releases[need]
+			if !ok {
+				return fmt.Errorf("release %s needs %s which is not in the spec", r.Key(), need)
+			}
+			// the needed release would be uninstalled while it is needed
+			if r.IsInstalled() && !needed.IsInstalled() {
+				return fmt.Errorf("release %s needs %s which is not installed", r.Key(), need)
+			}
+		}
+	}
+
+	// depth first search for the cycles
+	visited, visiting := sets.NewString(), sets.NewString()
+	var visit func(key string) error
+	visit = func(key string) error {
+		if visiting.Has(key) {
+			return fmt.Errorf("release %s needs itself through its needs", key)
+		}
+		if visited.Has(key) {
+			return nil
+		}
+		visiting.Insert(key)
+		for _, need := range releases[key].needKeys() {
+			if err := visit(need); err != nil {
+				return err
+			}
+		}
+		visiting.Delete(key)
+		visited.Insert(key)
+		return nil
+	}
+	for i := range s.Releases {
+		if err := visit(s.Releases[i].Key()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -16,9 +16,376 @@ limitations under the License.
 
 package helm
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	utilexec "k8s.io/utils/exec"
+)
+
+// StatusReason is an enumeration of possible failure causes of helm commands.
+type StatusReason string
+
+const (
+	// StatusReasonUnknown means the failure can not be classified.
+	StatusReasonUnknown StatusReason = ""
+
+	// StatusReasonReleaseNotFound means the release does not exist, or has no
+	// deployed revision to upgrade.
+	StatusReasonReleaseNotFound StatusReason = "ReleaseNotFound"
+
+	// StatusReasonAlreadyExists means the release or the repository name is
+	// already in use.
+	StatusReasonAlreadyExists StatusReason = "AlreadyExists"
+
+	// StatusReasonChartNotFound means the chart or the chart version can not be
+	// found in the repository.
+	StatusReasonChartNotFound StatusReason = "ChartNotFound"
+
+	// StatusReasonRepoNotFound means the repository is not configured, or no
+	// chart repository can be found at its url.
+	StatusReasonRepoNotFound StatusReason = "RepoNotFound"
+
+	// StatusReasonConflict means another operation is in progress on the release.
+	StatusReasonConflict StatusReason = "Conflict"
+
+	// StatusReasonTimeout means the operation did not complete in time, either
+	// the helm timeout or the deadline of the context is exceeded.
+	StatusReasonTimeout StatusReason = "Timeout"
+
+	// StatusReasonServiceUnavailable means the kubernetes API server can not be reached.
+	StatusReasonServiceUnavailable StatusReason = "ServiceUnavailable"
+
+	// StatusReasonForbidden means the request is denied by the kubernetes API
+	// server or the file system.
+	StatusReasonForbidden StatusReason = "Forbidden"
+
+	// StatusReasonInvalid means the chart can not be rendered with the given
+	// values, or the rendered manifest is invalid.
+	StatusReasonInvalid StatusReason = "Invalid"
+)
 
 var (
 	// ErrReleaseNotFound returns a "release not found error".
-	ErrReleaseNotFound = errors.New("release not found")
+	ErrReleaseNotFound = &StatusError{Reason: StatusReasonReleaseNotFound, Message: "release not found"}
 )
+
+// NewReleaseNotFound returns a new error which indicates that the release of
+// the given name does not exist, it is mainly used by the fakes.
+func NewReleaseNotFound(name string) *StatusError {
+	return &StatusError{
+		Reason:  StatusReasonReleaseNotFound,
+		Message: fmt.Sprintf("release %q: release: not found", name),
+	}
+}
+
+// NewAlreadyExists returns a new error which indicates that the release of the
+// given name is still in use.
+func NewAlreadyExists(name string) *StatusError {
+	return &StatusError{
+		Reason:  StatusReasonAlreadyExists,
+		Message: fmt.Sprintf("release %q: cannot re-use a name that is still in use", name),
+	}
+}
+
+// NewRepoNotFound returns a new error which indicates that the repository of
+// the given name is not configured.
+func NewRepoNotFound(name string) *StatusError {
+	return &StatusError{
+		Reason:  StatusReasonRepoNotFound,
+		Message: fmt.Sprintf("no repo named %q found", name),
+	}
+}
+
+// reasonPatterns maps the error messages of helm to the failure reasons, they
+// are matched in order against the message which follows the "Error: " prefix
+// printed by helm on stderr. The patterns are anchored to the messages of helm
+// and kubernetes, so that a failure of a repository, the network or a registry
+// is not mistaken for another reason.
+var reasonPatterns = []struct {
+	reason   StatusReason
+	patterns []*regexp.Regexp
+}{
+	{StatusReasonConflict, compilePatterns(
+		`another operation \(install/upgrade/rollback\) is in progress`,
+		`the object has been modified; please apply your changes to the latest version`,
+	)},
+	{StatusReasonTimeout, compilePatterns(
+		`timed out waiting for the condition`,
+		`context deadline exceeded`,
+	)},
+	{StatusReasonForbidden, compilePatterns(
+		`is forbidden: User "[^"]*" cannot `,
+		`^Kubernetes cluster unreachable: the server has asked for the client to provide credentials`,
+		`^open [^:]+: permission denied$`,
+	)},
+	{StatusReasonServiceUnavailable, compilePatterns(
+		`^Kubernetes cluster unreachable`,
+		`the server is currently unable to handle the request`,
+	)},
+	{StatusReasonReleaseNotFound, compilePatterns(
+		`(^|: )release: not found$`,
+		`"[^"]*" has no deployed releases$`,
+	)},
+	{StatusReasonAlreadyExists, compilePatterns(
+		`(^|: )cannot re-use a name that is still in use$`,
+		`^repository name \([^)]*\) already exists, please specify a different name$`,
+	)},
+	{StatusReasonRepoNotFound, compilePatterns(
+		`^no repo named "[^"]*" found$`,
+		`^no repositories found\. You must add one before updating$`,
+		`^no repositories to show$`,
+		`^no repositories configured$`,
+		`(^|: )repo [^ ]+ not found$`,
+		`^looks like "[^"]*" is not a valid chart repository or cannot be reached`,
+	)},
+	{StatusReasonChartNotFound, compilePatterns(
+		// the download failures which wrap another cause are not matched
+		`(^|: )failed to download "[^"]*"( at version "[^"]*")?( \(hint: running .helm repo update. may help\))?$`,
+		`(^|: )chart "[^"]*" (version "[^"]*" |matching [^ ]+ )?not found in `,
+		`(^|: )no chart version found for `,
+		`(^|: )no chart name found$`,
+	)},
+	{StatusReasonInvalid, compilePatterns(
+		`parse error at \(`,
+		`execution error at \(`,
+		`error converting YAML to JSON`,
+		`unable to build kubernetes objects from release manifest`,
+		`values don't meet the specifications of the schema`,
+		`error validating data`,
+	)},
+}
+
+// compilePatterns compiles the patterns in multi-line mode, so that ^ and $
+// match at the lines of a message.
+func compilePatterns(patterns ...string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		res = append(res, regexp.MustCompile("(?m)"+pattern))
+	}
+
+	return res
+}
+
+// StatusError is an error intended for consumption by the callers, the reason
+// is classified from the output of the helm command.
+type StatusError struct {
+	Reason  StatusReason
+	Message string
+	// Err is the underlying error of the helm command, if any.
+	Err error
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is a StatusError with the same known reason,
+// so that errors.Is(err, ErrReleaseNotFound) matches any release not found error.
+func (e *StatusError) Is(target error) bool {
+	t, ok := target.(*StatusError)
+	if !ok {
+		return false
+	}
+
+	return e.Reason != StatusReasonUnknown && e.Reason == t.Reason
+}
+
+// ExecError is returned when the helm command fails, it keeps the exit code
+// and the separated output of the process.
+type ExecError struct {
+	// Args are the arguments of the helm command, without the binary.
+	Args []string
+	// ExitCode is the exit code of the process, -1 if the process did not exit
+	// normally, e.g. the helm binary is not found.
+	ExitCode int
+	Stdout   []byte
+	Stderr   []byte
+	// Err is the error returned by running the command.
+	Err error
+}
+
+func newExecError(args []string, stdout []byte, stderr []byte, err error) *ExecError {
+	exitCode := -1
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitStatus()
+	}
+
+	return &ExecError{
+		Args:     args,
+		ExitCode: exitCode,
+		Stdout:   stdout,
+		Stderr:   stderr,
+		Err:      err,
+	}
+}
+
+func (e *ExecError) Error() string {
+	var op string
+	if len(e.Args) != 0 {
+		op = e.Args[0]
+	}
+	stderr := strings.TrimSpace(string(e.Stderr))
+	if len(stderr) == 0 {
+		return fmt.Sprintf("helm %s failed with exit code %d: %v", op, e.ExitCode, e.Err)
+	}
+
+	return fmt.Sprintf("helm %s failed with exit code %d: %s", op, e.ExitCode, stderr)
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError is returned when the helm command is killed because the deadline
+// of the context passed by the caller is exceeded. It wraps the context error.
+type TimeoutError struct {
+	Op  string
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out while running helm %s: %v", e.Op, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// parseError returns a StatusError which describes the failure of a helm
+// command, msg is the context of the failure.
+func parseError(msg string, err error) error {
+	return &StatusError{
+		Reason:  reasonForExecError(err),
+		Message: fmt.Sprintf("%s: %v", msg, err),
+		Err:     err,
+	}
+}
+
+// reasonForExecError classifies the failure by the output of the helm command.
+func reasonForExecError(err error) StatusReason {
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) || errors.Is(err, context.DeadlineExceeded) {
+		return StatusReasonTimeout
+	}
+	var execErr *ExecError
+	if !errors.As(err, &execErr) {
+		return StatusReasonUnknown
+	}
+
+	msg, ok := errorMessage(execErr.Stderr)
+	if !ok {
+		return StatusReasonUnknown
+	}
+	for _, rp := range reasonPatterns {
+		for _, pattern := range rp.patterns {
+			if pattern.MatchString(msg) {
+				return rp.reason
+			}
+		}
+	}
+
+	return StatusReasonUnknown
+}
+
+// errorMessage returns the message printed by helm after the "Error: " prefix
+// on stderr, including the lines which follow it. The stdout is never looked
+// at, as it may contain the notes or the manifest of the chart.
+func errorMessage(stderr []byte) (string, bool) {
+	const prefix = "Error: "
+
+	lines := strings.Split(string(stderr), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.HasPrefix(lines[i], prefix) {
+			lines[i] = strings.TrimPrefix(lines[i], prefix)
+			return strings.TrimSpace(strings.Join(lines[i:], "\n")), true
+		}
+	}
+
+	return "", false
+}
+
+// ReasonForError returns the reason for a particular error.
+func ReasonForError(err error) StatusReason {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Reason
+	}
+
+	return StatusReasonUnknown
+}
+
+// IsNotFound returns true if the specified error indicates the release, the
+// chart or the repository does not exist. It supports wrapped errors and
+// returns false when the error is nil.
+func IsNotFound(err error) bool {
+	switch ReasonForError(err) {
+	case StatusReasonReleaseNotFound, StatusReasonChartNotFound, StatusReasonRepoNotFound:
+		return true
+	}
+
+	return false
+}
+
+// IsReleaseNotFound returns true if the specified error indicates the release
+// does not exist.
+func IsReleaseNotFound(err error) bool {
+	return ReasonForError(err) == StatusReasonReleaseNotFound
+}
+
+// IsChartNotFound returns true if the specified error indicates the chart or
+// the chart version does not exist.
+func IsChartNotFound(err error) bool {
+	return ReasonForError(err) == StatusReasonChartNotFound
+}
+
+// IsRepoNotFound returns true if the specified error indicates the repository
+// is not configured.
+func IsRepoNotFound(err error) bool {
+	return ReasonForError(err) == StatusReasonRepoNotFound
+}
+
+// IsAlreadyExists determines if the err is an error which indicates that a
+// specified release or repository already exists.
+func IsAlreadyExists(err error) bool {
+	return ReasonForError(err) == StatusReasonAlreadyExists
+}
+
+// IsConflict determines if the err is an error which indicates another
+// operation is in progress on the release.
+func IsConflict(err error) bool {
+	return ReasonForError(err) == StatusReasonConflict
+}
+
+// IsTimeout determines if err is an error which indicates that the operation
+// did not complete in time.
+func IsTimeout(err error) bool {
+	var timeoutErr *TimeoutError
+	return ReasonForError(err) == StatusReasonTimeout || errors.As(err, &timeoutErr)
+}
+
+// IsServiceUnavailable is true if the error indicates the kubernetes API
+// server can not be reached.
+func IsServiceUnavailable(err error) bool {
+	return ReasonForError(err) == StatusReasonServiceUnavailable
+}
+
+// IsForbidden determines if err is an error which indicates that the request
+// is forbidden and cannot be completed as requested.
+func IsForbidden(err error) bool {
+	return ReasonForError(err) == StatusReasonForbidden
+}
+
+// IsInvalid determines if the err is an error which indicates the chart can
+// not be rendered with the given values.
+func IsInvalid(err error) bool {
+	return ReasonForError(err) == StatusReasonInvalid
+}
@@ -16,4 +16,229 @@ limitations under the License.
 
 package helm
 
-// TODO
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	execErr := errors.New("exit status 1")
+
+	testCases := []struct {
+		name   string
+		err    error
+		out    string
+		reason StatusReason
+		check  func(error) bool
+	}{
+		{
+			name:   "release not found",
+			err:    execErr,
+			out:    "Error: release: not found",
+			reason: StatusReasonReleaseNotFound,
+			check:  IsNotFound,
+		},
+		{
+			name:   "upgrade without deployed release",
+			err:    execErr,
+			out:    `Error: UPGRADE FAILED: "nginx" has no deployed releases`,
+			reason: StatusReasonReleaseNotFound,
+			check:  IsNotFound,
+		},
+		{
+			name:   "release already exists",
+			err:    execErr,
+			out:    "Error: INSTALLATION FAILED: cannot re-use a name that is still in use",
+			reason: StatusReasonAlreadyExists,
+			check:  IsAlreadyExists,
+		},
+		{
+			name:   "chart not found",
+			err:    execErr,
+			out:    `Error: INSTALLATION FAILED: failed to download "bitnami/nginxx"`,
+			reason: StatusReasonChartNotFound,
+			check:  IsNotFound,
+		},
+		{
+			name:   "repo not found",
+			err:    execErr,
+			out:    `Error: no repo named "bitnami" found`,
+			reason: StatusReasonRepoNotFound,
+			check:  IsNotFound,
+		},
+		{
+			name:   "operation in progress",
+			err:    execErr,
+			out:    "Error: UPGRADE FAILED: another operation (install/upgrade/rollback) is in progress",
+			reason: StatusReasonConflict,
+			check:  IsConflict,
+		},
+		{
+			name:   "helm timeout",
+			err:    execErr,
+			out:    "Error: INSTALLATION FAILED: timed out waiting for the condition",
+			reason: StatusReasonTimeout,
+			check:  IsTimeout,
+		},
+		{
+			name:   "context deadline exceeded",
+			err:    &TimeoutError{Op: "install", Err: context.DeadlineExceeded},
+			reason: StatusReasonTimeout,
+			check:  IsTimeout,
+		},
+		{
+			name:   "cluster unreachable",
+			err:    execErr,
+			out:    "Error: Kubernetes cluster unreachable: Get \"https://127.0.0.1:6443/version\": dial tcp 127.0.0.1:6443: connect: connection refused",
+			reason: StatusReasonServiceUnavailable,
+			check:  IsServiceUnavailable,
+		},
+		{
+			name:   "permission denied",
+			err:    execErr,
+			out:    `Error: list: failed to list: secrets is forbidden: User "demo" cannot list resource "secrets"`,
+			reason: StatusReasonForbidden,
+			check:  IsForbidden,
+		},
+		{
+			name:   "invalid values",
+			err:    execErr,
+			out:    "Error: INSTALLATION FAILED: template: nginx/templates/svc.yaml:3:11: executing \"nginx/templates/svc.yaml\" at <.Values.name>: execution error at (nginx/templates/svc.yaml:3:11): name is required",
+			reason: StatusReasonInvalid,
+			check:  IsInvalid,
+		},
+		{
+			name:   "chart version not found",
+			err:    execErr,
+			out:    `Error: INSTALLATION FAILED: chart "nginx" version "99.0.0" not found in https://charts.bitnami.com/bitnami repository`,
+			reason: StatusReasonChartNotFound,
+			check:  IsChartNotFound,
+		},
+		{
+			name:   "repo already exists",
+			err:    execErr,
+			out:    "Error: repository name (bitnami) already exists, please specify a different name",
+			reason: StatusReasonAlreadyExists,
+			check:  IsAlreadyExists,
+		},
+		{
+			name:   "invalid repo url",
+			err:    execErr,
+			out:    `Error: looks like "https://charts.example.com" is not a valid chart repository or cannot be reached: Get "https://charts.example.com/index.yaml": dial tcp: lookup charts.example.com: no such host`,
+			reason: StatusReasonRepoNotFound,
+			check:  IsRepoNotFound,
+		},
+		{
+			name:   "error after warnings",
+			err:    execErr,
+			out:    "WARNING: Kubernetes configuration file is group-readable. This is insecure.\nError: release: not found\n",
+			reason: StatusReasonReleaseNotFound,
+			check:  IsReleaseNotFound,
+		},
+		{
+			name:   "unknown",
+			err:    execErr,
+			out:    "Error: something went wrong",
+			reason: StatusReasonUnknown,
+			check:  func(err error) bool { return !IsNotFound(err) && !IsTimeout(err) },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.err
+			if len(tc.out) != 0 {
+				err = &ExecError{Args: []string{"test"}, ExitCode: 1, Stderr: []byte(tc.out), Err: tc.err}
+			}
+			err = fmt.Errorf("wrapped: %w", parseError("error test", err))
+			if reason := ReasonForError(err); reason != tc.reason {
+				t.Errorf("expected reason %q, got %q", tc.reason, reason)
+			}
+			if !tc.check(err) {
+				t.Errorf("unexpected check result for error %v", err)
+			}
+			if !errors.Is(err, tc.err) {
+				t.Errorf("expected the error to wrap %v", tc.err)
+			}
+		})
+	}
+}
+
+func TestParseErrorUnknown(t *testing.T) {
+	testCases := []struct {
+		name   string
+		stdout string
+		stderr string
+	}{
+		{
+			name:   "registry unauthorized",
+			stderr: "Error: INSTALLATION FAILED: failed to authorize: failed to fetch anonymous token: unexpected status: 401 Unauthorized",
+		},
+		{
+			name:   "registry host not found",
+			stderr: `Error: INSTALLATION FAILED: failed to download "oci://registry.example.com/charts/nginx": dial tcp: lookup registry.example.com: no such host`,
+		},
+		{
+			name:   "download with a cause",
+			stderr: `Error: failed to download "bitnami/nginx": Get "https://charts.bitnami.com/bitnami/nginx-9.0.0.tgz": net/http: TLS handshake timeout`,
+		},
+		{
+			name:   "repo connection refused",
+			stderr: `Error: Get "https://charts.example.com/index.yaml": dial tcp 10.0.0.1:443: connect: connection refused`,
+		},
+		{
+			name:   "notes on stdout",
+			stdout: "NOTES:\nError: release: not found is printed if the release is forbidden\n",
+			stderr: "Error: something went wrong",
+		},
+		{
+			name:   "warning only",
+			stderr: "WARNING: the secrets are forbidden, permission denied\nError: something went wrong",
+		},
+		{
+			name:   "no error prefix",
+			stderr: "release: not found",
+		},
+		{
+			name:   "release name in the message",
+			stderr: `Error: INSTALLATION FAILED: rendered manifests contain a resource that already exists. Unable to continue with install: ServiceAccount "release: not found" exists`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := parseError("error test", &ExecError{
+				Args:     []string{"test"},
+				ExitCode: 1,
+				Stdout:   []byte(tc.stdout),
+				Stderr:   []byte(tc.stderr),
+				Err:      errors.New("exit status 1"),
+			})
+			if reason := ReasonForError(err); reason != StatusReasonUnknown {
+				t.Errorf("expected unknown reason, got %q", reason)
+			}
+		})
+	}
+}
+
+func TestErrReleaseNotFound(t *testing.T) {
+	err := parseError("error get release status", &ExecError{
+		Args:     []string{"status"},
+		ExitCode: 1,
+		Stderr:   []byte("Error: release: not found"),
+		Err:      errors.New("exit status 1"),
+	})
+	if !errors.Is(err, ErrReleaseNotFound) {
+		t.Errorf("expected %v to be a release not found error", err)
+	}
+	if !IsNotFound(ErrReleaseNotFound) {
+		t.Errorf("expected ErrReleaseNotFound to be a not found error")
+	}
+
+	err = parseError("error get release status", errors.New("exit status 1"))
+	if errors.Is(err, ErrReleaseNotFound) {
+		t.Errorf("expected %v not to be a release not found error", err)
+	}
+}
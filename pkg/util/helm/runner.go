@@ -17,8 +17,16 @@ limitations under the License.
 package helm
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -30,56 +38,210 @@ import (
 )
 
 type Interface interface {
-	Install(namespace string, name string, opts metav1.InstallOptions) error
-	Delete(namespace string, name string, opts metav1.DeleteOptions) error
-	Get(namespace string, name string) ([]byte, error)
-	List(namespace string) ([]byte, error)
+	Install(ctx context.Context, namespace string, name string, opts metav1.InstallOptions) ([]byte, error)
+	Template(ctx context.Context, namespace string, name string, opts metav1.InstallOptions) ([]byte, error)
+	Upgrade(ctx context.Context, namespace string, name string, opts metav1.UpgradeOptions) ([]byte, error)
+	Rollback(ctx context.Context, namespace string, name string, revision int, opts metav1.RollbackOptions) error
+	History(ctx context.Context, namespace string, name string, opts metav1.HistoryOptions) ([]byte, error)
+	Delete(ctx context.Context, namespace string, name string, opts metav1.DeleteOptions) ([]byte, error)
+	Get(ctx context.Context, namespace string, name string) ([]byte, error)
+	Status(ctx context.Context, namespace string, name string, opts metav1.StatusOptions) ([]byte, error)
+	GetValues(ctx context.Context, namespace string, name string, opts metav1.GetValuesOptions) ([]byte, error)
+	GetManifest(ctx context.Context, namespace string, name string, opts metav1.GetManifestOptions) ([]byte, error)
+	GetNotes(ctx context.Context, namespace string, name string, opts metav1.GetNotesOptions) ([]byte, error)
+	GetHooks(ctx context.Context, namespace string, name string, opts metav1.GetHooksOptions) ([]byte, error)
+	List(ctx context.Context, namespace string, opts metav1.ListOptions) ([]byte, error)
+
+	RepoAdd(ctx context.Context, name string, url string, opts metav1.RepoAddOptions) error
+	RepoList(ctx context.Context) ([]byte, error)
+	RepoRemove(ctx context.Context, names ...string) error
+	RepoUpdate(ctx context.Context, names ...string) error
+
+	SearchRepo(ctx context.Context, keyword string, opts metav1.SearchOptions) ([]byte, error)
+	ShowChart(ctx context.Context, opts metav1.ShowOptions) ([]byte, error)
+	ShowValues(ctx context.Context, opts metav1.ShowOptions) ([]byte, error)
+	ShowReadme(ctx context.Context, opts metav1.ShowOptions) ([]byte, error)
+	ShowCRDs(ctx context.Context, opts metav1.ShowOptions) ([]byte, error)
+	ShowAll(ctx context.Context, opts metav1.ShowOptions) ([]byte, error)
 }
 
 const (
 	cmdHelm string = "helm"
+
+	// envKubeToken is the environment variable of the bearer token of helm.
+	envKubeToken = "HELM_KUBETOKEN"
 )
 
 type operation string
 
 const (
-	opInstall operation = "install"
-	opList    operation = "list"
-	opDelete  operation = "delete"
-	opCreate  operation = "create"
+	opInstall  operation = "install"
+	opUpgrade  operation = "upgrade"
+	opRollback operation = "rollback"
+	opHistory  operation = "history"
+	opStatus   operation = "status"
+	opGet      operation = "get"
+	opRepo     operation = "repo"
+	opSearch   operation = "search"
+	opShow     operation = "show"
+	opList     operation = "list"
+	opDelete   operation = "delete"
+	opCreate   operation = "create"
 )
 
 // Namespace represents different ns for helm (k8s)
 type Namespace string
 
+// Config holds the settings of the helm runner.
+type Config struct {
+	// Binary is the path of the helm binary, the "helm" in $PATH is used if
+	// not set.
+	Binary string
+
+	// KubeConfig is the path of the kubeconfig file passed to helm.
+	KubeConfig string
+	// KubeContext is the name of the kubeconfig context to use.
+	KubeContext string
+	// APIServer is the address and the port for the Kubernetes API server.
+	APIServer string
+	// BearerToken is the bearer token used for authentication, it is passed
+	// by the environment so that it never shows up in the process list.
+	BearerToken string
+	// CAFile is the certificate authority file for the Kubernetes API server connection.
+	CAFile string
+	// ImpersonateUser is the username to impersonate for the operation.
+	ImpersonateUser string
+	// ImpersonateGroups are the groups to impersonate for the operation.
+	ImpersonateGroups []string
+	// InsecureSkipTLSVerify skips the verification of the Kubernetes API
+	// server's certificate.
+	InsecureSkipTLSVerify bool
+
+	// RegistryConfig is the path to the registry config file.
+	RegistryConfig string
+	// RepositoryConfig is the path to the file containing repository names and URLs.
+	RepositoryConfig string
+	// RepositoryCache is the path to the file containing cached repository indexes.
+	RepositoryCache string
+
+	// BurstLimit is the client-side default throttling limit, zero means the helm default.
+	BurstLimit int
+	// Env are the extra environment variables of helm, such as HELM_CACHE_HOME
+	// and HELM_DRIVER. The helm process inherits the environment of the current
+	// process with these variables set on top of it, the environment of the
+	// current process is never changed. HELM_KUBETOKEN is always taken from
+	// BearerToken if it is set.
+	Env map[string]string
+	// Debug enables the verbose output of helm, which is logged.
+	Debug bool
+
+	// WarningHandler handles the warnings printed by helm on stderr, the
+	// warnings are logged if it is not set.
+	WarningHandler WarningHandler
+}
+
 // runner implements Interface in terms of exec("helm").
 type runner struct {
-	mu         sync.Mutex
-	exec       utilexec.Interface
-	kubeConfig string
+	mu             sync.Mutex
+	exec           utilexec.Interface
+	config         Config
+	binary         string
+	warningHandler WarningHandler
 }
 
-func New(exec utilexec.Interface, kubeconfig string) Interface {
+func New(exec utilexec.Interface, config Config) Interface {
+	binary := config.Binary
+	if len(binary) == 0 {
+		binary = cmdHelm
+	}
+	warningHandler := config.WarningHandler
+	if warningHandler == nil {
+		warningHandler = WarningLogger{}
+	}
+
 	return &runner{
-		exec:       exec,
-		kubeConfig: kubeconfig,
+		exec:           exec,
+		config:         config,
+		binary:         binary,
+		warningHandler: warningHandler,
 	}
 }
 
-func (runner *runner) Install(namespace string, name string, opts metav1.InstallOptions) error {
+// Install installs a chart archive, and returns the release printed by
+// `helm install -o json`.
+func (runner *runner) Install(ctx context.Context, namespace string, name string, opts metav1.InstallOptions) ([]byte, error) {
 	trace := utiltrace.New("helm install")
 	defer trace.LogIfLong(2 * time.Second)
 
-	if len(name) == 0 {
-		return fmt.Errorf("name can not be empty when install release")
+	args, err := makeInstallArgs(name, opts)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, []string{"-o", "json"}...)
+	stdin, err := valuesStdin(opts.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	fullArgs := runner.makeFullArgs(namespace, args...)
+	out, err := runner.runContextWithStdin(ctx, opInstall, fullArgs, stdin)
+	if err != nil {
+		return nil, parseError("error install release", err)
+	}
+
+	return out, nil
+}
+
+// Template renders a release without installing it, and returns the release
+// printed by `helm install --dry-run -o json`.
+func (runner *runner) Template(ctx context.Context, namespace string, name string, opts metav1.InstallOptions) ([]byte, error) {
+	trace := utiltrace.New("helm template")
+	defer trace.LogIfLong(2 * time.Second)
+
+	args, err := makeInstallArgs(name, opts)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, []string{"--dry-run", "-o", "json"}...)
+	stdin, err := valuesStdin(opts.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	fullArgs := runner.makeFullArgs(namespace, args...)
+	out, err := runner.runContextWithStdin(ctx, opInstall, fullArgs, stdin)
+	if err != nil {
+		return nil, parseError("error template release", err)
+	}
+
+	return out, nil
+}
+
+// makeInstallArgs returns the args of `helm install` without the global flags,
+// the name must be empty if it is generated by helm.
+func makeInstallArgs(name string, opts metav1.InstallOptions) ([]string, error) {
+	generateName := opts.GenerateName || len(opts.NameTemplate) != 0
+	if len(name) == 0 && !generateName {
+		return nil, fmt.Errorf("name can not be empty when install release")
+	}
+	if len(name) != 0 && generateName {
+		return nil, fmt.Errorf("name must be empty when generate the name of release")
 	}
 	// TODO: only supported install chart relase by reference for now
 	if opts.ChartReference == "" {
-		return fmt.Errorf("chart reference can not be empty when install release")
+		return nil, fmt.Errorf("chart reference can not be empty when install release")
 	}
 
 	// setup args
-	args := []string{name, opts.ChartReference}
+	var args []string
+	switch {
+	case len(opts.NameTemplate) != 0:
+		args = []string{opts.ChartReference, "--name-template", opts.NameTemplate}
+	case opts.GenerateName:
+		args = []string{opts.ChartReference, "--generate-name"}
+	default:
+		args = []string{name, opts.ChartReference}
+	}
 	if opts.CreateNamespace {
 		args = append(args, "--create-namespace")
 	}
@@ -89,116 +251,725 @@ func (runner *runner) Install(namespace string, name string, opts metav1.Install
 	if opts.Wait {
 		args = append(args, "--wait")
 	}
-	if len(opts.ValuesFiles) != 0 {
-		for _, valuesFile := range opts.ValuesFiles {
-			// TODO: To ensure the yaml file exists
-			args = append(args, []string{"-f", valuesFile}...)
-		}
+	args = appendValuesArgs(args, opts.ValuesFiles, opts.Values, opts.ValuesSets)
+
+	return args, nil
+}
+
+// Upgrade upgrades a release to a new version of a chart, and returns the
+// release printed by `helm upgrade -o json`.
+func (runner *runner) Upgrade(ctx context.Context, namespace string, name string, opts metav1.UpgradeOptions) ([]byte, error) {
+	trace := utiltrace.New("helm upgrade")
+	defer trace.LogIfLong(2 * time.Second)
+
+	if len(name) == 0 {
+		return nil, fmt.Errorf("name can not be empty when upgrade release")
+	}
+	if opts.ChartReference == "" {
+		return nil, fmt.Errorf("chart reference can not be empty when upgrade release")
+	}
+	if opts.ReuseValues && opts.ResetValues {
+		return nil, fmt.Errorf("reuse values and reset values can not be set at the same time")
 	}
-	if len(opts.ValuesSets) != 0 {
-		for k, v := range opts.ValuesSets {
-			args = append(args, []string{"--set", fmt.Sprintf("%s=%s", k, v)}...)
+
+	// setup args
+	args := []string{name, opts.ChartReference}
+	if opts.Install {
+		args = append(args, "--install")
+		if opts.CreateNamespace {
+			args = append(args, "--create-namespace")
 		}
 	}
+	if opts.Version != nil {
+		args = append(args, []string{"--version", *opts.Version}...)
+	}
+	if opts.ReuseValues {
+		args = append(args, "--reuse-values")
+	}
+	if opts.ResetValues {
+		args = append(args, "--reset-values")
+	}
+	if opts.Atomic {
+		args = append(args, "--atomic")
+	}
+	if opts.Wait {
+		args = append(args, "--wait")
+	}
+	if opts.Timeout > 0 {
+		args = append(args, []string{"--timeout", opts.Timeout.String()}...)
+	}
+	if opts.Force {
+		args = append(args, "--force")
+	}
+	args = appendValuesArgs(args, opts.ValuesFiles, opts.Values, opts.ValuesSets)
+	args = append(args, []string{"-o", "json"}...)
+	stdin, err := valuesStdin(opts.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	fullArgs := runner.makeFullArgs(namespace, args...)
+	out, err := runner.runContextWithStdin(ctx, opUpgrade, fullArgs, stdin)
+	if err != nil {
+		return nil, parseError("error upgrade release", err)
+	}
+
+	return out, nil
+}
+
+// Rollback rolls back a release to the given revision, zero means the previous one.
+func (runner *runner) Rollback(ctx context.Context, namespace string, name string, revision int, opts metav1.RollbackOptions) error {
+	trace := utiltrace.New("helm rollback")
+	defer trace.LogIfLong(2 * time.Second)
+
+	if len(name) == 0 {
+		return fmt.Errorf("name can not be empty when rollback release")
+	}
+	if revision < 0 {
+		return fmt.Errorf("invalid revision %d when rollback release", revision)
+	}
+
+	// setup args
+	args := []string{name}
+	if revision > 0 {
+		args = append(args, strconv.Itoa(revision))
+	}
+	if opts.Wait {
+		args = append(args, "--wait")
+	}
+	if opts.Timeout > 0 {
+		args = append(args, []string{"--timeout", opts.Timeout.String()}...)
+	}
+	if opts.Force {
+		args = append(args, "--force")
+	}
+	if opts.DisableHooks {
+		args = append(args, "--no-hooks")
+	}
+	if opts.CleanupOnFail {
+		args = append(args, "--cleanup-on-fail")
+	}
 
 	fullArgs := runner.makeFullArgs(namespace, args...)
-	if out, err := runner.runContext(context.TODO(), opInstall, fullArgs); err != nil {
-		return fmt.Errorf("error install release: %v: %s", err, out)
+	if _, err := runner.runContext(ctx, opRollback, fullArgs); err != nil {
+		return parseError("error rollback release", err)
 	}
 
 	return nil
 }
 
-func (runner *runner) Delete(namespace string, name string, opts metav1.DeleteOptions) error {
+// History returns the revisions of a release printed by `helm history -o json`.
+func (runner *runner) History(ctx context.Context, namespace string, name string, opts metav1.HistoryOptions) ([]byte, error) {
+	trace := utiltrace.New("helm history")
+	defer trace.LogIfLong(2 * time.Second)
+
+	if len(name) == 0 {
+		return nil, fmt.Errorf("name can not be empty when get release history")
+	}
+
+	// setup args
+	args := []string{name}
+	if opts.Max > 0 {
+		args = append(args, []string{"--max", strconv.Itoa(opts.Max)}...)
+	}
+	args = append(args, []string{"-o", "json"}...)
+
+	fullArgs := runner.makeFullArgs(namespace, args...)
+	out, err := runner.runContext(ctx, opHistory, fullArgs)
+	if err != nil {
+		return nil, parseError("error get release history", err)
+	}
+
+	return out, nil
+}
+
+// Delete uninstalls a release, and returns the output of `helm uninstall`.
+func (runner *runner) Delete(ctx context.Context, namespace string, name string, opts metav1.DeleteOptions) ([]byte, error) {
 	trace := utiltrace.New("helm delete")
 	defer trace.LogIfLong(2 * time.Second)
 
-	fullArgs := runner.makeFullArgs(namespace, name)
-	klog.V(4).Infof("running %s %v", cmdHelm, fullArgs)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
+	if len(name) == 0 {
+		return nil, fmt.Errorf("name can not be empty when delete release")
+	}
 
-	out, err := runner.runContext(ctx, opDelete, fullArgs)
-	if ctx.Err() == context.DeadlineExceeded {
-		return fmt.Errorf("timed out while delete release %s", name)
+	// setup args
+	args := []string{name}
+	if opts.KeepHistory {
+		args = append(args, "--keep-history")
+	}
+	if opts.DisableHooks {
+		args = append(args, "--no-hooks")
+	}
+	if opts.Wait {
+		args = append(args, "--wait")
 	}
+	if opts.Timeout > 0 {
+		args = append(args, []string{"--timeout", opts.Timeout.String()}...)
+	}
+	if opts.DryRun {
+		args = append(args, "--dry-run")
+	}
+	switch opts.Cascade {
+	case "":
+	case metav1.DeletePropagationOrphan, metav1.DeletePropagationBackground, metav1.DeletePropagationForeground:
+		args = append(args, []string{"--cascade", string(opts.Cascade)}...)
+	default:
+		return nil, fmt.Errorf("invalid cascade %q when delete release", opts.Cascade)
+	}
+	if len(opts.Description) != 0 {
+		args = append(args, []string{"--description", opts.Description}...)
+	}
+
+	fullArgs := runner.makeFullArgs(namespace, args...)
+	out, err := runner.runContext(ctx, opDelete, fullArgs)
 	if err != nil {
-		return fmt.Errorf("error delete release: %v: %s", err, out)
+		return nil, parseError("error delete release", err)
 	}
 
-	return nil
+	return out, nil
 }
 
-func (runner *runner) Get(namespace string, name string) ([]byte, error) {
+// Get returns the release printed by `helm list --filter ^NAME$ --all -o json`,
+// the release is listed whatever its state, e.g. pending or uninstalled with
+// its history kept.
+func (runner *runner) Get(ctx context.Context, namespace string, name string) ([]byte, error) {
 	trace := utiltrace.New("helm get")
 	defer trace.LogIfLong(2 * time.Second)
 
 	// setup args
-	fullArgs := runner.makeFullArgs(namespace, []string{"-f", fmt.Sprintf("^%s$", name)}...)
+	fullArgs := runner.makeListArgs(namespace, []string{"-f", fmt.Sprintf("^%s$", regexp.QuoteMeta(name)), "--all"}...)
 	fullArgs = append(fullArgs, []string{"-o", "json"}...)
-	klog.V(4).Infof("running %s %v", cmdHelm, fullArgs)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
 	out, err := runner.runContext(ctx, opList, fullArgs)
-	if ctx.Err() == context.DeadlineExceeded {
-		return nil, fmt.Errorf("timed out while get release")
+	if err != nil {
+		return nil, parseError("error get release", err)
+	}
+
+	return out, nil
+}
+
+// Status returns the release printed by `helm status -o json`.
+func (runner *runner) Status(ctx context.Context, namespace string, name string, opts metav1.StatusOptions) ([]byte, error) {
+	trace := utiltrace.New("helm status")
+	defer trace.LogIfLong(2 * time.Second)
+
+	if len(name) == 0 {
+		return nil, fmt.Errorf("name can not be empty when get release status")
 	}
+
+	// setup args
+	args := []string{name}
+	if opts.Revision > 0 {
+		args = append(args, []string{"--revision", strconv.Itoa(opts.Revision)}...)
+	}
+	args = append(args, []string{"-o", "json"}...)
+
+	fullArgs := runner.makeFullArgs(namespace, args...)
+	out, err := runner.runContext(ctx, opStatus, fullArgs)
 	if err != nil {
-		return nil, fmt.Errorf("error get release: %v: %s", err, out)
+		return nil, parseError("error get release status", err)
 	}
 
 	return out, nil
 }
 
-func (runner *runner) List(namespace string) ([]byte, error) {
+// GetValues returns the values of a release printed by `helm get values -o json`.
+func (runner *runner) GetValues(ctx context.Context, namespace string, name string, opts metav1.GetValuesOptions) ([]byte, error) {
+	trace := utiltrace.New("helm get values")
+	defer trace.LogIfLong(2 * time.Second)
+
+	args := []string{"-o", "json"}
+	if opts.AllValues {
+		args = append(args, "--all")
+	}
+	return runner.get(ctx, namespace, "values", name, opts.Revision, args...)
+}
+
+// GetManifest returns the manifest of a release printed by `helm get manifest`.
+func (runner *runner) GetManifest(ctx context.Context, namespace string, name string, opts metav1.GetManifestOptions) ([]byte, error) {
+	trace := utiltrace.New("helm get manifest")
+	defer trace.LogIfLong(2 * time.Second)
+
+	return runner.get(ctx, namespace, "manifest", name, opts.Revision)
+}
+
+// GetNotes returns the notes of a release printed by `helm get notes`.
+func (runner *runner) GetNotes(ctx context.Context, namespace string, name string, opts metav1.GetNotesOptions) ([]byte, error) {
+	trace := utiltrace.New("helm get notes")
+	defer trace.LogIfLong(2 * time.Second)
+
+	return runner.get(ctx, namespace, "notes", name, opts.Revision)
+}
+
+// GetHooks returns the hooks of a release printed by `helm get hooks`.
+func (runner *runner) GetHooks(ctx context.Context, namespace string, name string, opts metav1.GetHooksOptions) ([]byte, error) {
+	trace := utiltrace.New("helm get hooks")
+	defer trace.LogIfLong(2 * time.Second)
+
+	return runner.get(ctx, namespace, "hooks", name, opts.Revision)
+}
+
+// get runs `helm get <subcommand>` against the release with the given revision,
+// zero means the latest revision.
+func (runner *runner) get(ctx context.Context, namespace string, subcommand string, name string, revision int, args ...string) ([]byte, error) {
+	if len(name) == 0 {
+		return nil, fmt.Errorf("name can not be empty when get release %s", subcommand)
+	}
+
+	// setup args
+	args = append([]string{subcommand, name}, args...)
+	if revision > 0 {
+		args = append(args, []string{"--revision", strconv.Itoa(revision)}...)
+	}
+
+	fullArgs := runner.makeFullArgs(namespace, args...)
+	out, err := runner.runContext(ctx, opGet, fullArgs)
+	if err != nil {
+		return nil, parseError(fmt.Sprintf("error get release %s", subcommand), err)
+	}
+
+	return out, nil
+}
+
+// List returns the releases printed by `helm list -o json`, the Continue of
+// opts is ignored, it should be converted to Offset by the caller.
+func (runner *runner) List(ctx context.Context, namespace string, opts metav1.ListOptions) ([]byte, error) {
 	//runner.mu.Lock()
 	//defer runner.mu.Unlock()
 	trace := utiltrace.New("helm list")
 	defer trace.LogIfLong(2 * time.Second)
 
-	fullArgs := runner.makeFullArgs(namespace)
-	fullArgs = append(fullArgs, []string{"-o", "json"}...)
+	if opts.Max < 0 || opts.Offset < 0 {
+		return nil, fmt.Errorf("max and offset can not be negative when list release")
+	}
 
-	klog.V(4).Infof("running %s %v", cmdHelm, fullArgs)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
+	// setup args
+	var args []string
+	if len(opts.Filter) != 0 {
+		args = append(args, []string{"--filter", opts.Filter}...)
+	}
+	if opts.All {
+		args = append(args, "--all")
+	}
+	if opts.Deployed {
+		args = append(args, "--deployed")
+	}
+	if opts.Failed {
+		args = append(args, "--failed")
+	}
+	if opts.Pending {
+		args = append(args, "--pending")
+	}
+	if opts.Superseded {
+		args = append(args, "--superseded")
+	}
+	if opts.Uninstalled {
+		args = append(args, "--uninstalled")
+	}
+	if opts.Uninstalling {
+		args = append(args, "--uninstalling")
+	}
+	if len(opts.Selector) != 0 {
+		args = append(args, []string{"--selector", opts.Selector}...)
+	}
+	if opts.SortByDate {
+		args = append(args, "--date")
+	}
+	if opts.Reverse {
+		args = append(args, "--reverse")
+	}
+	// helm takes zero max as no limit
+	args = append(args, []string{"--max", strconv.Itoa(opts.Max)}...)
+	if opts.Offset > 0 {
+		args = append(args, []string{"--offset", strconv.Itoa(opts.Offset)}...)
+	}
+	args = append(args, []string{"-o", "json"}...)
 
+	fullArgs := runner.makeListArgs(namespace, args...)
 	out, err := runner.runContext(ctx, opList, fullArgs)
-	if ctx.Err() == context.DeadlineExceeded {
-		return nil, fmt.Errorf("timed out while list release")
+	if err != nil {
+		return nil, parseError("error list release", err)
 	}
-	if err == nil {
-		return out, nil
+
+	return out, nil
+}
+
+// RepoAdd adds a chart repository, the password is passed by stdin so that it
+// never shows up in the process list.
+func (runner *runner) RepoAdd(ctx context.Context, name string, url string, opts metav1.RepoAddOptions) error {
+	trace := utiltrace.New("helm repo add")
+	defer trace.LogIfLong(2 * time.Second)
+
+	if len(name) == 0 || len(url) == 0 {
+		return fmt.Errorf("name and url can not be empty when add repo")
 	}
 
-	return nil, fmt.Errorf("error list release: %v: %s", err, out)
+	// setup args
+	args := []string{"add", name, url}
+	if len(opts.Username) != 0 {
+		args = append(args, []string{"--username", opts.Username}...)
+	}
+	var stdin io.Reader
+	if len(opts.Password) != 0 {
+		args = append(args, "--password-stdin")
+		stdin = strings.NewReader(opts.Password)
+	}
+	if len(opts.CAFile) != 0 {
+		args = append(args, []string{"--ca-file", opts.CAFile}...)
+	}
+	if len(opts.CertFile) != 0 {
+		args = append(args, []string{"--cert-file", opts.CertFile}...)
+	}
+	if len(opts.KeyFile) != 0 {
+		args = append(args, []string{"--key-file", opts.KeyFile}...)
+	}
+	if opts.InsecureSkipTLSVerify {
+		args = append(args, "--insecure-skip-tls-verify")
+	}
+	if opts.ForceUpdate {
+		args = append(args, "--force-update")
+	}
+
+	fullArgs := runner.makeGlobalArgs(args...)
+	if _, err := runner.runContextWithStdin(ctx, opRepo, fullArgs, stdin); err != nil {
+		return parseError("error add repo", err)
+	}
+
+	return nil
 }
 
-func (runner *runner) makeFullArgs(namespace string, args ...string) []string {
-	if len(runner.kubeConfig) != 0 {
-		args = append(args, []string{"--kubeconfig", runner.kubeConfig}...)
+// RepoList returns the chart repositories printed by `helm repo list -o json`.
+func (runner *runner) RepoList(ctx context.Context) ([]byte, error) {
+	trace := utiltrace.New("helm repo list")
+	defer trace.LogIfLong(2 * time.Second)
+
+	fullArgs := runner.makeGlobalArgs("list", "-o", "json")
+	out, err := runner.runContext(ctx, opRepo, fullArgs)
+	if err != nil {
+		return nil, parseError("error list repo", err)
+	}
+
+	return out, nil
+}
+
+// RepoRemove removes one or more chart repositories.
+func (runner *runner) RepoRemove(ctx context.Context, names ...string) error {
+	trace := utiltrace.New("helm repo remove")
+	defer trace.LogIfLong(2 * time.Second)
+
+	if len(names) == 0 {
+		return fmt.Errorf("names can not be empty when remove repo")
+	}
+
+	fullArgs := runner.makeGlobalArgs(append([]string{"remove"}, names...)...)
+	if _, err := runner.runContext(ctx, opRepo, fullArgs); err != nil {
+		return parseError("error remove repo", err)
+	}
+
+	return nil
+}
+
+// RepoUpdate updates the information of available charts from the given chart
+// repositories, all of the repositories are updated if no names are given.
+func (runner *runner) RepoUpdate(ctx context.Context, names ...string) error {
+	trace := utiltrace.New("helm repo update")
+	defer trace.LogIfLong(2 * time.Second)
+
+	fullArgs := runner.makeGlobalArgs(append([]string{"update"}, names...)...)
+	if _, err := runner.runContext(ctx, opRepo, fullArgs); err != nil {
+		return parseError("error update repo", err)
+	}
+
+	return nil
+}
+
+// SearchRepo returns the charts printed by `helm search repo -o json`, the
+// charts which match the keyword in all of the repositories are returned if
+// the keyword is empty.
+func (runner *runner) SearchRepo(ctx context.Context, keyword string, opts metav1.SearchOptions) ([]byte, error) {
+	trace := utiltrace.New("helm search repo")
+	defer trace.LogIfLong(2 * time.Second)
+
+	// setup args
+	args := []string{"repo"}
+	if len(keyword) != 0 {
+		args = append(args, keyword)
+	}
+	if opts.Regexp {
+		args = append(args, "--regexp")
+	}
+	if opts.Versions {
+		args = append(args, "--versions")
+	}
+	if opts.Devel {
+		args = append(args, "--devel")
+	}
+	if len(opts.Version) != 0 {
+		args = append(args, []string{"--version", opts.Version}...)
+	}
+	args = append(args, []string{"-o", "json"}...)
+
+	fullArgs := runner.makeGlobalArgs(args...)
+	out, err := runner.runContext(ctx, opSearch, fullArgs)
+	if err != nil {
+		return nil, parseError("error search repo", err)
+	}
+
+	return out, nil
+}
+
+// ShowChart returns the Chart.yaml of a chart printed by `helm show chart`.
+func (runner *runner) ShowChart(ctx context.Context, opts metav1.ShowOptions) ([]byte, error) {
+	trace := utiltrace.New("helm show chart")
+	defer trace.LogIfLong(2 * time.Second)
+
+	return runner.show(ctx, "chart", opts)
+}
+
+// ShowValues returns the default values of a chart printed by `helm show values`.
+func (runner *runner) ShowValues(ctx context.Context, opts metav1.ShowOptions) ([]byte, error) {
+	trace := utiltrace.New("helm show values")
+	defer trace.LogIfLong(2 * time.Second)
+
+	return runner.show(ctx, "values", opts)
+}
+
+// ShowReadme returns the README of a chart printed by `helm show readme`.
+func (runner *runner) ShowReadme(ctx context.Context, opts metav1.ShowOptions) ([]byte, error) {
+	trace := utiltrace.New("helm show readme")
+	defer trace.LogIfLong(2 * time.Second)
+
+	return runner.show(ctx, "readme", opts)
+}
+
+// ShowCRDs returns the CRDs of a chart printed by `helm show crds`.
+func (runner *runner) ShowCRDs(ctx context.Context, opts metav1.ShowOptions) ([]byte, error) {
+	trace := utiltrace.New("helm show crds")
+	defer trace.LogIfLong(2 * time.Second)
+
+	return runner.show(ctx, "crds", opts)
+}
+
+// ShowAll returns all of the information of a chart printed by `helm show all`.
+func (runner *runner) ShowAll(ctx context.Context, opts metav1.ShowOptions) ([]byte, error) {
+	trace := utiltrace.New("helm show all")
+	defer trace.LogIfLong(2 * time.Second)
+
+	return runner.show(ctx, "all", opts)
+}
+
+// show runs `helm show <subcommand>` against a chart.
+func (runner *runner) show(ctx context.Context, subcommand string, opts metav1.ShowOptions) ([]byte, error) {
+	if opts.ChartReference == "" {
+		return nil, fmt.Errorf("chart reference can not be empty when show chart %s", subcommand)
+	}
+
+	// setup args
+	args := []string{subcommand, opts.ChartReference}
+	if opts.Version != nil {
+		args = append(args, []string{"--version", *opts.Version}...)
+	}
+
+	fullArgs := runner.makeGlobalArgs(args...)
+	out, err := runner.runContext(ctx, opShow, fullArgs)
+	if err != nil {
+		return nil, parseError(fmt.Sprintf("error show chart %s", subcommand), err)
+	}
+
+	return out, nil
+}
+
+// appendValuesArgs appends the values files, the values from stdin and the
+// sorted `--set` values to args, in the order helm merges them.
+func appendValuesArgs(args []string, valuesFiles []string, values map[string]interface{}, valuesSets map[string]string) []string {
+	for _, valuesFile := range valuesFiles {
+		// TODO: To ensure the yaml file exists
+		args = append(args, []string{"-f", valuesFile}...)
+	}
+	// the values are read from stdin, see valuesStdin
+	if len(values) != 0 {
+		args = append(args, []string{"-f", "-"}...)
+	}
+
+	keys := make([]string, 0, len(valuesSets))
+	for k := range valuesSets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, []string{"--set", fmt.Sprintf("%s=%s", k, valuesSets[k])}...)
+	}
+
+	return args
+}
+
+// valuesStdin returns the values as the values file read by helm from stdin,
+// JSON is used since it is valid YAML.
+func valuesStdin(values map[string]interface{}) (io.Reader, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("marshal values failed %v", err)
+	}
+
+	return bytes.NewReader(data), nil
+}
+
+// makeGlobalArgs appends the global flags to args, it is used directly by the
+// commands which are not namespaced.
+func (runner *runner) makeGlobalArgs(args ...string) []string {
+	c := runner.config
+	if len(c.KubeConfig) != 0 {
+		args = append(args, []string{"--kubeconfig", c.KubeConfig}...)
+	}
+	if len(c.KubeContext) != 0 {
+		args = append(args, []string{"--kube-context", c.KubeContext}...)
+	}
+	if len(c.APIServer) != 0 {
+		args = append(args, []string{"--kube-apiserver", c.APIServer}...)
 	}
+	if len(c.CAFile) != 0 {
+		args = append(args, []string{"--kube-ca-file", c.CAFile}...)
+	}
+	if len(c.ImpersonateUser) != 0 {
+		args = append(args, []string{"--kube-as-user", c.ImpersonateUser}...)
+	}
+	for _, group := range c.ImpersonateGroups {
+		args = append(args, []string{"--kube-as-group", group}...)
+	}
+	if c.InsecureSkipTLSVerify {
+		args = append(args, "--kube-insecure-skip-tls-verify")
+	}
+	if len(c.RegistryConfig) != 0 {
+		args = append(args, []string{"--registry-config", c.RegistryConfig}...)
+	}
+	if len(c.RepositoryConfig) != 0 {
+		args = append(args, []string{"--repository-config", c.RepositoryConfig}...)
+	}
+	if len(c.RepositoryCache) != 0 {
+		args = append(args, []string{"--repository-cache", c.RepositoryCache}...)
+	}
+	if c.BurstLimit > 0 {
+		args = append(args, []string{"--burst-limit", strconv.Itoa(c.BurstLimit)}...)
+	}
+	if c.Debug {
+		args = append(args, "--debug")
+	}
+
+	return args
+}
+
+// makeEnv returns the environment of helm, nil means the environment of the
+// current process is inherited as is. The variables of the Env override the
+// ones of the current process, and the BearerToken overrides both, each
+// variable is only set once.
+func (runner *runner) makeEnv() []string {
+	c := runner.config
+	if len(c.Env) == 0 && len(c.BearerToken) == 0 {
+		return nil
+	}
+
+	overrides := make(map[string]string, len(c.Env)+1)
+	for k, v := range c.Env {
+		overrides[k] = v
+	}
+	if len(c.BearerToken) != 0 {
+		overrides[envKubeToken] = c.BearerToken
+	}
+
+	var env []string
+	for _, kv := range os.Environ() {
+		k := kv
+		if i := strings.Index(kv, "="); i >= 0 {
+			k = kv[:i]
+		}
+		if _, ok := overrides[k]; !ok {
+			env = append(env, kv)
+		}
+	}
+
+	keys := make([]string, 0, len(overrides))
+	for k := range overrides {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, fmt.Sprintf("%s=%s", k, overrides[k]))
+	}
+
+	return env
+}
+
+// makeFullArgs appends the global flags and the namespace to args, helm uses
+// the namespace of the kubeconfig context if the namespace is empty.
+func (runner *runner) makeFullArgs(namespace string, args ...string) []string {
+	args = runner.makeGlobalArgs(args...)
 
 	if len(namespace) == 0 {
-		return append(args, []string{"--all-namespaces"}...)
+		return args
 	}
 
 	return append(args, []string{"-n", namespace}...)
 }
 
+// makeListArgs appends the global flags and the namespace to the args of
+// `helm list`, which lists the releases of all the namespaces if the
+// namespace is empty.
+func (runner *runner) makeListArgs(namespace string, args ...string) []string {
+	if len(namespace) == 0 {
+		return append(runner.makeGlobalArgs(args...), []string{"--all-namespaces"}...)
+	}
+
+	return runner.makeFullArgs(namespace, args...)
+}
+
 func (runner *runner) run(op operation, args []string) ([]byte, error) {
 	return runner.runContext(context.TODO(), op, args)
 }
 
 func (runner *runner) runContext(ctx context.Context, op operation, args []string) ([]byte, error) {
+	return runner.runContextWithStdin(ctx, op, args, nil)
+}
+
+// runContextWithStdin runs the helm command and returns its stdout, the stderr
+// is only kept in the ExecError if the command fails, the warnings in it are
+// passed to the warning handler.
+func (runner *runner) runContextWithStdin(ctx context.Context, op operation, args []string, stdin io.Reader) ([]byte, error) {
 	fullArgs := []string{string(op)}
 	fullArgs = append(fullArgs, args...)
 
-	klog.V(5).Infof("running helm: %s %v", cmdHelm, fullArgs)
+	klog.V(5).Infof("running helm: %s %v", runner.binary, fullArgs)
 	if ctx == nil {
-		return runner.exec.Command(cmdHelm, fullArgs...).CombinedOutput()
+		ctx = context.TODO()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := runner.exec.CommandContext(ctx, runner.binary, fullArgs...)
+	if env := runner.makeEnv(); env != nil {
+		cmd.SetEnv(env)
+	}
+	if stdin != nil {
+		cmd.SetStdin(stdin)
+	}
+	cmd.SetStdout(&stdout)
+	cmd.SetStderr(&stderr)
+	err := cmd.Run()
+	handleWarnings(runner.warningHandler, stderr.Bytes())
+
+	if err != nil {
+		// The helm process is killed once the context is done, report the
+		// context error rather than the "signal: killed" of the process. A
+		// command which succeeded is not failed by a context done afterwards.
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			return stdout.Bytes(), &TimeoutError{Op: string(op), Err: ctx.Err()}
+		case context.Canceled:
+			return stdout.Bytes(), fmt.Errorf("canceled while running helm %s: %w", op, ctx.Err())
+		}
+		return stdout.Bytes(), newExecError(fullArgs, stdout.Bytes(), stderr.Bytes(), err)
 	}
 
-	return runner.exec.CommandContext(ctx, cmdHelm, fullArgs...).CombinedOutput()
+	return stdout.Bytes(), nil
 }
@@ -14,6 +14,572 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package helm
+package helm_test
 
-// TODO
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	utilhelm "github.com/caoyingjunz/client-helm/pkg/util/helm"
+	helmtesting "github.com/caoyingjunz/client-helm/pkg/util/helm/testing"
+)
+
+func TestInstallArgs(t *testing.T) {
+	version := "1.2.3"
+
+	testCases := []struct {
+		name      string
+		namespace string
+		release   string
+		opts      metav1.InstallOptions
+		expected  []string
+	}{
+		{
+			name:      "chart reference",
+			namespace: "default",
+			release:   "nginx",
+			opts:      metav1.InstallOptions{ChartReference: "bitnami/nginx"},
+			expected:  []string{"install", "nginx", "bitnami/nginx", "-o", "json", "-n", "default"},
+		},
+		{
+			name:      "all options",
+			namespace: "web",
+			release:   "nginx",
+			opts: metav1.InstallOptions{
+				ChartReference:  "bitnami/nginx",
+				CreateNamespace: true,
+				Version:         &version,
+				Wait:            true,
+				ValuesFiles:     []string{"values.yaml"},
+				ValuesSets:      map[string]string{"replicas": "2", "image.tag": "latest"},
+			},
+			expected: []string{"install", "nginx", "bitnami/nginx", "--create-namespace", "--version", "1.2.3", "--wait",
+				"-f", "values.yaml", "--set", "image.tag=latest", "--set", "replicas=2", "-o", "json", "-n", "web"},
+		},
+		{
+			name:      "generate name",
+			namespace: "web",
+			opts:      metav1.InstallOptions{ChartReference: "bitnami/nginx", GenerateName: true},
+			expected:  []string{"install", "bitnami/nginx", "--generate-name", "-o", "json", "-n", "web"},
+		},
+		{
+			name:      "name template",
+			namespace: "web",
+			opts:      metav1.InstallOptions{ChartReference: "bitnami/nginx", NameTemplate: "web-{{randAlpha 5}}"},
+			expected:  []string{"install", "bitnami/nginx", "--name-template", "web-{{randAlpha 5}}", "-o", "json", "-n", "web"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			script := helmtesting.NewScript()
+			script.Expect(tc.expected...).Returns(`{"name":"nginx"}`)
+
+			runner := utilhelm.New(script, utilhelm.Config{})
+			out, err := runner.Install(context.TODO(), tc.namespace, tc.release, tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(out) != `{"name":"nginx"}` {
+				t.Errorf("unexpected output: %s", out)
+			}
+			script.AssertExpectations(t)
+		})
+	}
+}
+
+func TestValuesStdin(t *testing.T) {
+	script := helmtesting.NewScript()
+	install := script.Expect("install", "nginx", "bitnami/nginx", "-f", "values.yaml", "-f", "-", "--set", "replicas=3", "-o", "json", "-n", "web").
+		Returns(`{"name":"nginx"}`)
+	upgrade := script.Expect("upgrade", "nginx", "bitnami/nginx", "-f", "-", "-o", "json", "-n", "web").
+		Returns(`{"name":"nginx"}`)
+
+	runner := utilhelm.New(script, utilhelm.Config{})
+	values := map[string]interface{}{"image": map[string]interface{}{"tag": "1.21"}, "replicas": 2}
+	_, err := runner.Install(context.TODO(), "web", "nginx", metav1.InstallOptions{
+		ChartReference: "bitnami/nginx",
+		ValuesFiles:    []string{"values.yaml"},
+		ValuesSets:     map[string]string{"replicas": "3"},
+		Values:         values,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = runner.Upgrade(context.TODO(), "web", "nginx", metav1.UpgradeOptions{ChartReference: "bitnami/nginx", Values: values}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, inv := range []*helmtesting.Invocation{install, upgrade} {
+		if stdin := inv.Stdin(); stdin != `{"image":{"tag":"1.21"},"replicas":2}` {
+			t.Errorf("unexpected values in stdin: %q", stdin)
+		}
+	}
+	script.AssertExpectations(t)
+}
+
+func TestValuesArgs(t *testing.T) {
+	values := map[string]interface{}{"replicas": 2}
+	testCases := []struct {
+		name     string
+		run      func(runner utilhelm.Interface) error
+		expected []string
+		stdin    string
+	}{
+		{
+			name: "template",
+			run: func(runner utilhelm.Interface) error {
+				_, err := runner.Template(context.TODO(), "web", "nginx", metav1.InstallOptions{ChartReference: "bitnami/nginx", Values: values})
+				return err
+			},
+			expected: []string{"install", "nginx", "bitnami/nginx", "-f", "-", "--dry-run", "-o", "json", "-n", "web"},
+			stdin:    `{"replicas":2}`,
+		},
+		{
+			name: "values only",
+			run: func(runner utilhelm.Interface) error {
+				_, err := runner.Install(context.TODO(), "web", "nginx", metav1.InstallOptions{ChartReference: "bitnami/nginx", Values: values})
+				return err
+			},
+			expected: []string{"install", "nginx", "bitnami/nginx", "-f", "-", "-o", "json", "-n", "web"},
+			stdin:    `{"replicas":2}`,
+		},
+		{
+			name: "no values",
+			run: func(runner utilhelm.Interface) error {
+				_, err := runner.Upgrade(context.TODO(), "web", "nginx", metav1.UpgradeOptions{ChartReference: "bitnami/nginx", ValuesFiles: []string{"values.yaml"}})
+				return err
+			},
+			expected: []string{"upgrade", "nginx", "bitnami/nginx", "-f", "values.yaml", "-o", "json", "-n", "web"},
+		},
+		{
+			name: "empty values",
+			run: func(runner utilhelm.Interface) error {
+				_, err := runner.Upgrade(context.TODO(), "web", "nginx", metav1.UpgradeOptions{ChartReference: "bitnami/nginx", Values: map[string]interface{}{}})
+				return err
+			},
+			expected: []string{"upgrade", "nginx", "bitnami/nginx", "-o", "json", "-n", "web"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			script := helmtesting.NewScript()
+			inv := script.Expect(tc.expected...).Returns(`{"name":"nginx"}`)
+
+			runner := utilhelm.New(script, utilhelm.Config{})
+			if err := tc.run(runner); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if stdin := inv.Stdin(); stdin != tc.stdin {
+				t.Errorf("expected values %q in stdin, got %q", tc.stdin, stdin)
+			}
+			script.AssertExpectations(t)
+		})
+	}
+}
+
+func TestValuesInvalid(t *testing.T) {
+	script := helmtesting.NewScript()
+	runner := utilhelm.New(script, utilhelm.Config{})
+
+	values := map[string]interface{}{"replicas": make(chan int)}
+	if _, err := runner.Install(context.TODO(), "web", "nginx", metav1.InstallOptions{ChartReference: "bitnami/nginx", Values: values}); err == nil {
+		t.Errorf("expected install error, got nil")
+	}
+	if _, err := runner.Template(context.TODO(), "web", "nginx", metav1.InstallOptions{ChartReference: "bitnami/nginx", Values: values}); err == nil {
+		t.Errorf("expected template error, got nil")
+	}
+	if _, err := runner.Upgrade(context.TODO(), "web", "nginx", metav1.UpgradeOptions{ChartReference: "bitnami/nginx", Values: values}); err == nil {
+		t.Errorf("expected upgrade error, got nil")
+	}
+	script.AssertExpectations(t)
+}
+
+func TestInstallInvalidOptions(t *testing.T) {
+	testCases := []struct {
+		name    string
+		release string
+		opts    metav1.InstallOptions
+	}{
+		{name: "empty name", opts: metav1.InstallOptions{ChartReference: "bitnami/nginx"}},
+		{name: "name and generate name", release: "nginx", opts: metav1.InstallOptions{ChartReference: "bitnami/nginx", GenerateName: true}},
+		{name: "empty chart reference", release: "nginx"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			script := helmtesting.NewScript()
+			runner := utilhelm.New(script, utilhelm.Config{})
+			if _, err := runner.Install(context.TODO(), "default", tc.release, tc.opts); err == nil {
+				t.Errorf("expected error, got nil")
+			}
+			script.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteArgs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     metav1.DeleteOptions
+		expected []string
+	}{
+		{
+			name:     "default",
+			expected: []string{"delete", "nginx", "-n", "web"},
+		},
+		{
+			name: "all options",
+			opts: metav1.DeleteOptions{
+				KeepHistory:  true,
+				DisableHooks: true,
+				Wait:         true,
+				Timeout:      5 * time.Minute,
+				DryRun:       true,
+				Cascade:      metav1.DeletePropagationForeground,
+				Description:  "cleanup",
+			},
+			expected: []string{"delete", "nginx", "--keep-history", "--no-hooks", "--wait", "--timeout", "5m0s", "--dry-run",
+				"--cascade", "foreground", "--description", "cleanup", "-n", "web"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			script := helmtesting.NewScript()
+			script.Expect(tc.expected...).Returns(`release "nginx" uninstalled`)
+
+			runner := utilhelm.New(script, utilhelm.Config{})
+			if _, err := runner.Delete(context.TODO(), "web", "nginx", tc.opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			script.AssertExpectations(t)
+		})
+	}
+
+	script := helmtesting.NewScript()
+	runner := utilhelm.New(script, utilhelm.Config{})
+	if _, err := runner.Delete(context.TODO(), "web", "nginx", metav1.DeleteOptions{Cascade: "unknown"}); err == nil {
+		t.Errorf("expected error for invalid cascade, got nil")
+	}
+	script.AssertExpectations(t)
+}
+
+func TestGetArgs(t *testing.T) {
+	script := helmtesting.NewScript()
+	script.Expect("list", "-f", `^nginx\.v1$`, "--all", "-n", "web", "-o", "json").Returns("[]")
+	script.Expect("list", "-f", "^nginx$", "--all", "--all-namespaces", "-o", "json").Returns("[]")
+
+	runner := utilhelm.New(script, utilhelm.Config{})
+	if _, err := runner.Get(context.TODO(), "web", "nginx.v1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := runner.Get(context.TODO(), "", "nginx"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	script.AssertExpectations(t)
+}
+
+func TestEmptyNamespaceArgs(t *testing.T) {
+	script := helmtesting.NewScript()
+	script.Expect("upgrade", "nginx", "bitnami/nginx", "-o", "json").Returns(`{"name":"nginx"}`)
+	script.Expect("rollback", "nginx", "1").Returns("")
+	script.Expect("history", "nginx", "-o", "json").Returns("[]")
+	script.Expect("status", "nginx", "-o", "json").Returns("{}")
+	script.Expect("get", "values", "nginx", "-o", "json").Returns("{}")
+
+	// only helm list takes --all-namespaces, the other commands use the
+	// namespace of the kubeconfig context
+	runner := utilhelm.New(script, utilhelm.Config{})
+	if _, err := runner.Upgrade(context.TODO(), "", "nginx", metav1.UpgradeOptions{ChartReference: "bitnami/nginx"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := runner.Rollback(context.TODO(), "", "nginx", 1, metav1.RollbackOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := runner.History(context.TODO(), "", "nginx", metav1.HistoryOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := runner.Status(context.TODO(), "", "nginx", metav1.StatusOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := runner.GetValues(context.TODO(), "", "nginx", metav1.GetValuesOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	script.AssertExpectations(t)
+}
+
+func TestListArgs(t *testing.T) {
+	testCases := []struct {
+		name      string
+		namespace string
+		config    utilhelm.Config
+		opts      metav1.ListOptions
+		expected  []string
+	}{
+		{
+			name:      "all namespaces",
+			expected:  []string{"list", "--max", "0", "-o", "json", "--all-namespaces"},
+			namespace: "",
+		},
+		{
+			name:      "states and paging",
+			namespace: "web",
+			opts: metav1.ListOptions{
+				Filter:     "^ng",
+				Deployed:   true,
+				Failed:     true,
+				Selector:   "team=web",
+				SortByDate: true,
+				Reverse:    true,
+				Max:        10,
+				Offset:     20,
+			},
+			expected: []string{"list", "--filter", "^ng", "--deployed", "--failed", "--selector", "team=web", "--date", "--reverse",
+				"--max", "10", "--offset", "20", "-o", "json", "-n", "web"},
+		},
+		{
+			name:      "global flags",
+			namespace: "web",
+			config: utilhelm.Config{
+				KubeConfig:        "/etc/kubeconfig",
+				KubeContext:       "prod",
+				ImpersonateGroups: []string{"a", "b"},
+				BurstLimit:        200,
+			},
+			expected: []string{"list", "--max", "0", "-o", "json", "--kubeconfig", "/etc/kubeconfig", "--kube-context", "prod",
+				"--kube-as-group", "a", "--kube-as-group", "b", "--burst-limit", "200", "-n", "web"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			script := helmtesting.NewScript()
+			script.Expect(tc.expected...).Returns("[]")
+
+			runner := utilhelm.New(script, tc.config)
+			if _, err := runner.List(context.TODO(), tc.namespace, tc.opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			script.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGlobalArgs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   utilhelm.Config
+		expected []string
+	}{
+		{
+			name:     "no global flags",
+			expected: []string{"status", "nginx", "-o", "json", "-n", "web"},
+		},
+		{
+			name: "all global flags",
+			config: utilhelm.Config{
+				KubeConfig:            "/etc/kubeconfig",
+				KubeContext:           "prod",
+				APIServer:             "https://10.0.0.1:6443",
+				BearerToken:           "token",
+				CAFile:                "/etc/ca.crt",
+				ImpersonateUser:       "admin",
+				ImpersonateGroups:     []string{"ops"},
+				InsecureSkipTLSVerify: true,
+				RegistryConfig:        "/etc/helm/registry.json",
+				RepositoryConfig:      "/etc/helm/repositories.yaml",
+				RepositoryCache:       "/var/cache/helm",
+				BurstLimit:            100,
+				Debug:                 true,
+			},
+			expected: []string{"status", "nginx", "-o", "json",
+				"--kubeconfig", "/etc/kubeconfig", "--kube-context", "prod", "--kube-apiserver", "https://10.0.0.1:6443",
+				"--kube-ca-file", "/etc/ca.crt", "--kube-as-user", "admin", "--kube-as-group", "ops", "--kube-insecure-skip-tls-verify",
+				"--registry-config", "/etc/helm/registry.json", "--repository-config", "/etc/helm/repositories.yaml",
+				"--repository-cache", "/var/cache/helm", "--burst-limit", "100", "--debug", "-n", "web"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			script := helmtesting.NewScript()
+			script.Expect(tc.expected...).Returns("{}")
+
+			runner := utilhelm.New(script, tc.config)
+			if _, err := runner.Status(context.TODO(), "web", "nginx", metav1.StatusOptions{}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			script.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRunnerEnv(t *testing.T) {
+	for k, v := range map[string]string{
+		"CLIENT_HELM_TEST_KEPT":     "process",
+		"CLIENT_HELM_TEST_OVERRIDE": "process",
+		"HELM_KUBETOKEN":            "process-token",
+	} {
+		old, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		defer func(k string) {
+			if ok {
+				os.Setenv(k, old)
+			} else {
+				os.Unsetenv(k)
+			}
+		}(k)
+	}
+
+	testCases := []struct {
+		name     string
+		config   utilhelm.Config
+		inherit  bool
+		expected map[string]string
+	}{
+		{
+			name:    "inherited",
+			inherit: true,
+		},
+		{
+			name:   "env overrides the process",
+			config: utilhelm.Config{Env: map[string]string{"CLIENT_HELM_TEST_OVERRIDE": "config", "HELM_DRIVER": "configmap"}},
+			expected: map[string]string{
+				"CLIENT_HELM_TEST_KEPT":     "process",
+				"CLIENT_HELM_TEST_OVERRIDE": "config",
+				"HELM_DRIVER":               "configmap",
+				"HELM_KUBETOKEN":            "process-token",
+			},
+		},
+		{
+			name: "bearer token overrides the env and the process",
+			config: utilhelm.Config{
+				BearerToken: "config-token",
+				Env:         map[string]string{"HELM_KUBETOKEN": "env-token"},
+			},
+			expected: map[string]string{
+				"CLIENT_HELM_TEST_KEPT":     "process",
+				"CLIENT_HELM_TEST_OVERRIDE": "process",
+				"HELM_KUBETOKEN":            "config-token",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			script := helmtesting.NewScript()
+			inv := script.Expect("status", "nginx", "-o", "json", "-n", "web").Returns("{}")
+
+			runner := utilhelm.New(script, tc.config)
+			if _, err := runner.Status(context.TODO(), "web", "nginx", metav1.StatusOptions{}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			script.AssertExpectations(t)
+
+			if tc.inherit {
+				if inv.Cmd.Env != nil {
+					t.Errorf("expected the environment to be inherited, got %v", inv.Cmd.Env)
+				}
+				return
+			}
+			// each variable is only set once
+			got := map[string][]string{}
+			for _, kv := range inv.Cmd.Env {
+				parts := strings.SplitN(kv, "=", 2)
+				got[parts[0]] = append(got[parts[0]], parts[1])
+			}
+			for k, v := range tc.expected {
+				if len(got[k]) != 1 || got[k][0] != v {
+					t.Errorf("expected %s=%s, got %v", k, v, got[k])
+				}
+			}
+		})
+	}
+
+	if v := os.Getenv("CLIENT_HELM_TEST_OVERRIDE"); v != "process" {
+		t.Errorf("expected the environment of the process unchanged, got %s", v)
+	}
+}
+
+func TestRunnerFailure(t *testing.T) {
+	script := helmtesting.NewScript()
+	script.Expect("status", "nginx", "-o", "json", "-n", "web").Fails(1, "Error: release: not found\n")
+
+	runner := utilhelm.New(script, utilhelm.Config{})
+	_, err := runner.Status(context.TODO(), "web", "nginx", metav1.StatusOptions{})
+	if !utilhelm.IsReleaseNotFound(err) {
+		t.Errorf("expected release not found error, got %v", err)
+	}
+	var execErr *utilhelm.ExecError
+	if !errors.As(err, &execErr) || execErr.ExitCode != 1 {
+		t.Errorf("expected exec error with exit code 1, got %v", err)
+	}
+	script.AssertExpectations(t)
+}
+
+func TestRunnerContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	script := helmtesting.NewScript()
+	script.Expect("upgrade", "nginx", "bitnami/nginx", "-o", "json", "-n", "web").Returns(`{"name":"nginx"}`)
+	script.Expect("upgrade", "nginx", "bitnami/nginx", "-o", "json", "-n", "web").Fails(-1, "")
+
+	runner := utilhelm.New(script, utilhelm.Config{})
+	opts := metav1.UpgradeOptions{ChartReference: "bitnami/nginx"}
+	// helm exited before the context is done, the upgrade is applied
+	if out, err := runner.Upgrade(ctx, "web", "nginx", opts); err != nil || string(out) != `{"name":"nginx"}` {
+		t.Errorf("expected the upgrade to succeed, got %s, %v", out, err)
+	}
+	if _, err := runner.Upgrade(ctx, "web", "nginx", opts); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled error, got %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.TODO(), 0)
+	defer cancel()
+	script.Expect("upgrade", "nginx", "bitnami/nginx", "-o", "json", "-n", "web").Fails(-1, "")
+	if _, err := runner.Upgrade(ctx, "web", "nginx", opts); !utilhelm.IsTimeout(err) {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+	script.AssertExpectations(t)
+}
+
+func TestRunnerSecrets(t *testing.T) {
+	script := helmtesting.NewScript()
+	script.Binary = "/usr/local/bin/helm"
+	inv := script.Expect("repo", "add", "private", "https://charts.example.com", "--username", "admin", "--password-stdin")
+
+	runner := utilhelm.New(script, utilhelm.Config{Binary: "/usr/local/bin/helm", BearerToken: "secret-token"})
+	err := runner.RepoAdd(context.TODO(), "private", "https://charts.example.com", metav1.RepoAddOptions{
+		Username: "admin",
+		Password: "secret-password",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdin := inv.Stdin(); stdin != "secret-password" {
+		t.Errorf("expected the password in stdin, got %q", stdin)
+	}
+	found := false
+	for _, env := range inv.Cmd.Env {
+		if env == "HELM_KUBETOKEN=secret-token" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the token in env, got %v", inv.Cmd.Env)
+	}
+	for _, arg := range inv.Cmd.Argv {
+		if strings.Contains(arg, "secret") {
+			t.Errorf("unexpected secret in args: %v", inv.Cmd.Argv)
+		}
+	}
+	script.AssertExpectations(t)
+}
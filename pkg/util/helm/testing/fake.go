@@ -15,3 +15,379 @@ limitations under the License.
 */
 
 package testing
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	utilexec "k8s.io/utils/exec"
+	fakeexec "k8s.io/utils/exec/testing"
+
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+)
+
+// TestingT is the subset of testing.TB used by the assertions.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Script is a scripted exec for the helm runner, the invocations must happen
+// in the order they are expected. Pass it to helm.New as the exec.
+type Script struct {
+	// FakeExec runs the scripted commands.
+	FakeExec *fakeexec.FakeExec
+	// Binary is the expected helm binary, it is not checked if empty.
+	Binary string
+
+	mu          sync.Mutex
+	invocations []*Invocation
+	errs        []string
+}
+
+var _ utilexec.Interface = &Script{}
+
+// NewScript returns a script without any expected invocations.
+func NewScript() *Script {
+	return &Script{FakeExec: &fakeexec.FakeExec{}}
+}
+
+// Invocation is an expected invocation of helm and its canned result.
+type Invocation struct {
+	// Args are the expected arguments without the binary, the subcommand first.
+	Args []string
+	// Match matches the arguments instead of Args if it is set.
+	Match func(args []string) bool
+
+	Stdout []byte
+	Stderr []byte
+	// ExitCode is the exit code of helm, zero means success.
+	ExitCode int
+	// Err is returned by running the command if set, e.g. the binary is not found.
+	Err error
+
+	// Cmd is the command run by the runner, it is set once the invocation is
+	// consumed and keeps the environment and the stdin.
+	Cmd *fakeexec.FakeCmd
+
+	mismatch string
+}
+
+// Expect adds an invocation with the exact arguments, without the binary.
+func (s *Script) Expect(args ...string) *Invocation {
+	return s.add(&Invocation{Args: args})
+}
+
+// ExpectMatch adds an invocation whose arguments are matched by the function.
+func (s *Script) ExpectMatch(match func(args []string) bool) *Invocation {
+	return s.add(&Invocation{Match: match})
+}
+
+func (s *Script) add(inv *Invocation) *Invocation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.invocations = append(s.invocations, inv)
+	s.FakeExec.CommandScript = append(s.FakeExec.CommandScript, func(cmd string, args ...string) utilexec.Cmd {
+		if inv.mismatch = inv.check(s.Binary, cmd, args); len(inv.mismatch) != 0 {
+			s.mu.Lock()
+			s.errorf("%s", inv.mismatch)
+			s.mu.Unlock()
+		}
+		inv.Cmd = &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{inv.run}}
+		return fakeexec.InitFakeCmd(inv.Cmd, cmd, args...)
+	})
+
+	return inv
+}
+
+// Returns sets the stdout of a successful invocation.
+func (inv *Invocation) Returns(stdout string) *Invocation {
+	inv.Stdout = []byte(stdout)
+	return inv
+}
+
+// Fails sets the exit code and the stderr of a failed invocation.
+func (inv *Invocation) Fails(exitCode int, stderr string) *Invocation {
+	inv.ExitCode = exitCode
+	inv.Stderr = []byte(stderr)
+	return inv
+}
+
+// WithStderr sets the stderr of the invocation, such as the warnings.
+func (inv *Invocation) WithStderr(stderr string) *Invocation {
+	inv.Stderr = []byte(stderr)
+	return inv
+}
+
+// Stdin returns what the runner writes to the stdin of helm.
+func (inv *Invocation) Stdin() string {
+	if inv.Cmd == nil || inv.Cmd.Stdin == nil {
+		return ""
+	}
+	data, _ := ioutil.ReadAll(inv.Cmd.Stdin)
+	return string(data)
+}
+
+func (inv *Invocation) check(binary string, cmd string, args []string) string {
+	if len(binary) != 0 && cmd != binary {
+		return fmt.Sprintf("unexpected helm binary %q, expected %q", cmd, binary)
+	}
+	if inv.Match != nil {
+		if !inv.Match(args) {
+			return fmt.Sprintf("unexpected helm invocation %q", args)
+		}
+		return ""
+	}
+	if strings.Join(args, "\x00") != strings.Join(inv.Args, "\x00") {
+		return fmt.Sprintf("unexpected helm invocation:\n  got:  %q\n  want: %q", args, inv.Args)
+	}
+
+	return ""
+}
+
+func (inv *Invocation) run() ([]byte, []byte, error) {
+	if len(inv.mismatch) != 0 {
+		return nil, nil, fmt.Errorf("%s", inv.mismatch)
+	}
+	if inv.Err != nil {
+		return inv.Stdout, inv.Stderr, inv.Err
+	}
+	if inv.ExitCode != 0 {
+		return inv.Stdout, inv.Stderr, fakeexec.FakeExitError{Status: inv.ExitCode}
+	}
+
+	return inv.Stdout, inv.Stderr, nil
+}
+
+// Command returns the scripted command, the command fails if there are no
+// more expected invocations.
+func (s *Script) Command(cmd string, args ...string) utilexec.Cmd {
+	s.mu.Lock()
+	if s.FakeExec.CommandCalls >= len(s.FakeExec.CommandScript) {
+		s.errorf("unexpected helm invocation %q after all the expected invocations", args)
+		s.mu.Unlock()
+		fakeCmd := &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{func() ([]byte, []byte, error) {
+			return nil, nil, fmt.Errorf("unexpected helm invocation")
+		}}}
+		return fakeexec.InitFakeCmd(fakeCmd, cmd, args...)
+	}
+	s.mu.Unlock()
+
+	return s.FakeExec.Command(cmd, args...)
+}
+
+// CommandContext returns the scripted command, the context is ignored.
+func (s *Script) CommandContext(ctx context.Context, cmd string, args ...string) utilexec.Cmd {
+	return s.Command(cmd, args...)
+}
+
+// LookPath returns the file as is.
+func (s *Script) LookPath(file string) (string, error) {
+	return file, nil
+}
+
+func (s *Script) errorf(format string, args ...interface{}) {
+	s.errs = append(s.errs, fmt.Sprintf(format, args...))
+}
+
+// Verify returns an error if an invocation is unexpected or an expected
+// invocation is not consumed.
+func (s *Script) Verify() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs := append([]string{}, s.errs...)
+	for _, inv := range s.invocations[s.FakeExec.CommandCalls:] {
+		if inv.Match != nil {
+			errs = append(errs, "expected helm invocation not consumed")
+			continue
+		}
+		errs = append(errs, fmt.Sprintf("expected helm invocation %q not consumed", inv.Args))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%s", strings.Join(errs, "\n"))
+}
+
+// AssertExpectations fails the test if Verify returns an error.
+func (s *Script) AssertExpectations(t TestingT) {
+	t.Helper()
+	if err := s.Verify(); err != nil {
+		t.Errorf("%v", err)
+	}
+}
+
+// FakeCall is a call of the FakeHelm.
+type FakeCall struct {
+	// Method is the name of the method, e.g. "Install".
+	Method    string
+	Namespace string
+	// Name is the release name, or the repository name, the keyword or the
+	// chart reference of the method.
+	Name string
+	// Options are the options of the method, or the other arguments, such as
+	// the revision of a rollback.
+	Options interface{}
+}
+
+// FakeResponse is the canned result of a method.
+type FakeResponse struct {
+	Out []byte
+	Err error
+}
+
+// FakeHelm is a fake helm.Interface, it records the calls and returns the
+// canned responses, an empty output is returned for methods without a response.
+type FakeHelm struct {
+	mu sync.Mutex
+	// Calls are the calls of the methods in order.
+	Calls []FakeCall
+	// Responses are the canned responses keyed by the method name, they are
+	// consumed in order and the last one is kept for the following calls.
+	Responses map[string][]FakeResponse
+}
+
+// NewFakeHelm returns a FakeHelm without any responses.
+func NewFakeHelm() *FakeHelm {
+	return &FakeHelm{Responses: make(map[string][]FakeResponse)}
+}
+
+// SetResponse appends a canned response of the method.
+func (f *FakeHelm) SetResponse(method string, out []byte, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Responses == nil {
+		f.Responses = make(map[string][]FakeResponse)
+	}
+	f.Responses[method] = append(f.Responses[method], FakeResponse{Out: out, Err: err})
+}
+
+// GetCalls returns a copy of the calls.
+func (f *FakeHelm) GetCalls() []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]FakeCall{}, f.Calls...)
+}
+
+func (f *FakeHelm) call(method string, namespace string, name string, opts interface{}) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.Calls = append(f.Calls, FakeCall{Method: method, Namespace: namespace, Name: name, Options: opts})
+	responses := f.Responses[method]
+	if len(responses) == 0 {
+		return []byte{}, nil
+	}
+	resp := responses[0]
+	if len(responses) > 1 {
+		f.Responses[method] = responses[1:]
+	}
+
+	return resp.Out, resp.Err
+}
+
+func (f *FakeHelm) Install(ctx context.Context, namespace string, name string, opts metav1.InstallOptions) ([]byte, error) {
+	return f.call("Install", namespace, name, opts)
+}
+
+func (f *FakeHelm) Template(ctx context.Context, namespace string, name string, opts metav1.InstallOptions) ([]byte, error) {
+	return f.call("Template", namespace, name, opts)
+}
+
+func (f *FakeHelm) Upgrade(ctx context.Context, namespace string, name string, opts metav1.UpgradeOptions) ([]byte, error) {
+	return f.call("Upgrade", namespace, name, opts)
+}
+
+// Rollback records the revision and the options as a []interface{}.
+func (f *FakeHelm) Rollback(ctx context.Context, namespace string, name string, revision int, opts metav1.RollbackOptions) error {
+	_, err := f.call("Rollback", namespace, name, []interface{}{revision, opts})
+	return err
+}
+
+func (f *FakeHelm) History(ctx context.Context, namespace string, name string, opts metav1.HistoryOptions) ([]byte, error) {
+	return f.call("History", namespace, name, opts)
+}
+
+func (f *FakeHelm) Delete(ctx context.Context, namespace string, name string, opts metav1.DeleteOptions) ([]byte, error) {
+	return f.call("Delete", namespace, name, opts)
+}
+
+func (f *FakeHelm) Get(ctx context.Context, namespace string, name string) ([]byte, error) {
+	return f.call("Get", namespace, name, nil)
+}
+
+func (f *FakeHelm) Status(ctx context.Context, namespace string, name string, opts metav1.StatusOptions) ([]byte, error) {
+	return f.call("Status", namespace, name, opts)
+}
+
+func (f *FakeHelm) GetValues(ctx context.Context, namespace string, name string, opts metav1.GetValuesOptions) ([]byte, error) {
+	return f.call("GetValues", namespace, name, opts)
+}
+
+func (f *FakeHelm) GetManifest(ctx context.Context, namespace string, name string, opts metav1.GetManifestOptions) ([]byte, error) {
+	return f.call("GetManifest", namespace, name, opts)
+}
+
+func (f *FakeHelm) GetNotes(ctx context.Context, namespace string, name string, opts metav1.GetNotesOptions) ([]byte, error) {
+	return f.call("GetNotes", namespace, name, opts)
+}
+
+func (f *FakeHelm) GetHooks(ctx context.Context, namespace string, name string, opts metav1.GetHooksOptions) ([]byte, error) {
+	return f.call("GetHooks", namespace, name, opts)
+}
+
+func (f *FakeHelm) List(ctx context.Context, namespace string, opts metav1.ListOptions) ([]byte, error) {
+	return f.call("List", namespace, "", opts)
+}
+
+// RepoAdd records the url and the options as a []interface{}.
+func (f *FakeHelm) RepoAdd(ctx context.Context, name string, url string, opts metav1.RepoAddOptions) error {
+	_, err := f.call("RepoAdd", "", name, []interface{}{url, opts})
+	return err
+}
+
+func (f *FakeHelm) RepoList(ctx context.Context) ([]byte, error) {
+	return f.call("RepoList", "", "", nil)
+}
+
+func (f *FakeHelm) RepoRemove(ctx context.Context, names ...string) error {
+	_, err := f.call("RepoRemove", "", "", names)
+	return err
+}
+
+func (f *FakeHelm) RepoUpdate(ctx context.Context, names ...string) error {
+	_, err := f.call("RepoUpdate", "", "", names)
+	return err
+}
+
+func (f *FakeHelm) SearchRepo(ctx context.Context, keyword string, opts metav1.SearchOptions) ([]byte, error) {
+	return f.call("SearchRepo", "", keyword, opts)
+}
+
+func (f *FakeHelm) ShowChart(ctx context.Context, opts metav1.ShowOptions) ([]byte, error) {
+	return f.call("ShowChart", "", opts.ChartReference, opts)
+}
+
+func (f *FakeHelm) ShowValues(ctx context.Context, opts metav1.ShowOptions) ([]byte, error) {
+	return f.call("ShowValues", "", opts.ChartReference, opts)
+}
+
+func (f *FakeHelm) ShowReadme(ctx context.Context, opts metav1.ShowOptions) ([]byte, error) {
+	return f.call("ShowReadme", "", opts.ChartReference, opts)
+}
+
+func (f *FakeHelm) ShowCRDs(ctx context.Context, opts metav1.ShowOptions) ([]byte, error) {
+	return f.call("ShowCRDs", "", opts.ChartReference, opts)
+}
+
+func (f *FakeHelm) ShowAll(ctx context.Context, opts metav1.ShowOptions) ([]byte, error) {
+	return f.call("ShowAll", "", opts.ChartReference, opts)
+}
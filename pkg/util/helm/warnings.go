@@ -0,0 +1,61 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+const warningPrefix = "WARNING:"
+
+// WarningHandler is an interface for handling the warnings printed by helm on
+// stderr, such as "WARNING: Kubernetes configuration file is group-readable".
+type WarningHandler interface {
+	// HandleWarning is called with the warning message without the "WARNING:" prefix.
+	HandleWarning(message string)
+}
+
+// WarningLogger is an implementation of WarningHandler that logs the warnings.
+type WarningLogger struct{}
+
+func (WarningLogger) HandleWarning(message string) {
+	klog.Warning(message)
+}
+
+// NoWarnings is an implementation of WarningHandler that suppresses the warnings.
+type NoWarnings struct{}
+
+func (NoWarnings) HandleWarning(message string) {}
+
+// handleWarnings passes the warnings in the stderr of helm to the handler, the
+// other lines, such as the debug logs, are only logged.
+func handleWarnings(handler WarningHandler, stderr []byte) {
+	for _, line := range strings.Split(string(stderr), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		if strings.HasPrefix(line, warningPrefix) {
+			handler.HandleWarning(strings.TrimSpace(strings.TrimPrefix(line, warningPrefix)))
+			continue
+		}
+		klog.V(4).Infof("helm: %s", line)
+	}
+}
@@ -0,0 +1,265 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/yaml"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+)
+
+const (
+	// APIVersionV1 is the v1 API version for index files.
+	APIVersionV1 = "v1"
+
+	// IndexFileName is the name of the index file in a chart repository.
+	IndexFileName = "index.yaml"
+
+	chartFileName = "Chart.yaml"
+)
+
+// NewIndexFile initializes an empty index file.
+func NewIndexFile() *v1.IndexFile {
+	return &v1.IndexFile{
+		APIVersion: APIVersionV1,
+		Generated:  metav1.NewTime(time.Now()),
+		Entries:    map[string][]v1.ChartVersion{},
+	}
+}
+
+// IndexDirectory reads the packaged charts (*.tgz) in dir and its direct
+// sub-directories, and generates an index file for them. The chart urls are
+// prefixed with baseURL if it is not empty.
+func IndexDirectory(dir string, baseURL string) (*v1.IndexFile, error) {
+	archives, err := filepath.Glob(filepath.Join(dir, "*.tgz"))
+	if err != nil {
+		return nil, err
+	}
+	moreArchives, err := filepath.Glob(filepath.Join(dir, "*", "*.tgz"))
+	if err != nil {
+		return nil, err
+	}
+	archives = append(archives, moreArchives...)
+
+	index := NewIndexFile()
+	for _, archive := range archives {
+		fname, err := filepath.Rel(dir, archive)
+		if err != nil {
+			return nil, err
+		}
+		md, err := LoadChartMetadata(archive)
+		if err != nil {
+			return nil, err
+		}
+		digest, err := DigestFile(archive)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = Add(index, *md, filepath.ToSlash(fname), baseURL, digest); err != nil {
+			return nil, err
+		}
+	}
+	SortEntries(index)
+
+	return index, nil
+}
+
+// Add adds a chart version to the index, nothing is changed if the version
+// of the chart is already in the index.
+func Add(index *v1.IndexFile, md v1.ChartMetadata, filename string, baseURL string, digest string) error {
+	if len(md.Name) == 0 || len(md.Version) == 0 {
+		return fmt.Errorf("invalid chart %s: name and version are required", filename)
+	}
+	if Has(index, md.Name, md.Version) {
+		return nil
+	}
+
+	url := filename
+	if len(baseURL) != 0 {
+		url = strings.TrimSuffix(baseURL, "/") + "/" + path.Clean(filename)
+	}
+	index.Entries[md.Name] = append(index.Entries[md.Name], v1.ChartVersion{
+		ChartMetadata: md,
+		URLs:          []string{url},
+		Created:       metav1.NewTime(time.Now()),
+		Digest:        digest,
+	})
+
+	return nil
+}
+
+// Has returns true if the index has an entry for a chart with the given name
+// and exact version.
+func Has(index *v1.IndexFile, name string, version string) bool {
+	for _, cv := range index.Entries[name] {
+		if cv.Version == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Merge merges the src index into dst, the entries of src are only added when
+// dst doesn't have the same chart version.
+func Merge(dst *v1.IndexFile, src *v1.IndexFile) {
+	if dst.Entries == nil {
+		dst.Entries = map[string][]v1.ChartVersion{}
+	}
+	for name, cvs := range src.Entries {
+		for _, cv := range cvs {
+			if !Has(dst, name, cv.Version) {
+				dst.Entries[name] = append(dst.Entries[name], cv)
+			}
+		}
+	}
+}
+
+// SortEntries sorts the chart versions of each entry from newest to oldest.
+func SortEntries(index *v1.IndexFile) {
+	for _, cvs := range index.Entries {
+		sort.SliceStable(cvs, func(i, j int) bool {
+			return versionGreater(cvs[i].Version, cvs[j].Version)
+		})
+	}
+}
+
+// LoadIndexFile loads an index file from the given path.
+func LoadIndexFile(filename string) (*v1.IndexFile, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &v1.IndexFile{}
+	if err = yaml.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("unmarshal to index file %s failed %v", filename, err)
+	}
+	if len(index.APIVersion) == 0 {
+		return nil, fmt.Errorf("no API version specified in index file %s", filename)
+	}
+	if index.Entries == nil {
+		index.Entries = map[string][]v1.ChartVersion{}
+	}
+
+	return index, nil
+}
+
+// WriteIndexFile writes the index file to the given path.
+func WriteIndexFile(index *v1.IndexFile, filename string) error {
+	data, err := yaml.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("marshal index file failed %v", err)
+	}
+
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// LoadChartMetadata reads the Chart.yaml from the top-level directory of a
+// packaged chart.
+func LoadChartMetadata(archive string) (*v1.ChartMetadata, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("read chart %s failed %v", archive, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read chart %s failed %v", archive, err)
+		}
+
+		// Only the Chart.yaml of the chart itself, not of the sub-charts.
+		parts := strings.Split(path.Clean(filepath.ToSlash(header.Name)), "/")
+		if len(parts) != 2 || parts[1] != chartFileName {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read chart %s failed %v", archive, err)
+		}
+		md := &v1.ChartMetadata{}
+		if err = yaml.Unmarshal(data, md); err != nil {
+			return nil, fmt.Errorf("unmarshal to chart metadata of %s failed %v", archive, err)
+		}
+		return md, nil
+	}
+
+	return nil, fmt.Errorf("no %s found in chart %s", chartFileName, archive)
+}
+
+// DigestFile calculates the SHA256 hash of a file, encoded as hex.
+func DigestFile(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// versionGreater compares two chart versions by semver, versions which are not
+// valid semver are compared as strings after the valid ones.
+func versionGreater(a string, b string) bool {
+	va, errA := version.ParseSemantic(strings.TrimPrefix(a, "v"))
+	vb, errB := version.ParseSemantic(strings.TrimPrefix(b, "v"))
+	switch {
+	case errA == nil && errB == nil:
+		return vb.LessThan(va)
+	case errA == nil:
+		return true
+	case errB == nil:
+		return false
+	}
+
+	return a > b
+}
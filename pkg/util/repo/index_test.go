@@ -0,0 +1,331 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+)
+
+// writeChart writes a packaged chart with the files to dir/filename, and
+// returns the sha256 digest of the archive.
+func writeChart(t *testing.T, dir, filename string, files map[string]string) string {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	path := filepath.Join(dir, filename)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:])
+}
+
+func chartYAML(name, version string) string {
+	return "apiVersion: v2\nname: " + name + "\nversion: " + version + "\n"
+}
+
+func TestIndexDirectory(t *testing.T) {
+	dir := t.TempDir()
+	digests := map[string]string{
+		"nginx-1.0.0.tgz": writeChart(t, dir, "nginx-1.0.0.tgz", map[string]string{
+			"nginx/Chart.yaml":                chartYAML("nginx", "1.0.0"),
+			"nginx/charts/common/Chart.yaml":  chartYAML("common", "0.1.0"),
+			"nginx/templates/deployment.yaml": "kind: Deployment\n",
+		}),
+		"nginx-1.1.0-rc.1.tgz": writeChart(t, dir, "nginx-1.1.0-rc.1.tgz", map[string]string{
+			"nginx/Chart.yaml": chartYAML("nginx", "1.1.0-rc.1"),
+		}),
+		"stable/redis-2.0.0.tgz": writeChart(t, dir, "stable/redis-2.0.0.tgz", map[string]string{
+			"redis/Chart.yaml": chartYAML("redis", "2.0.0"),
+		}),
+	}
+	// only the archives in dir and its direct sub-directories are indexed
+	writeChart(t, dir, "a/b/mysql-1.0.0.tgz", map[string]string{"mysql/Chart.yaml": chartYAML("mysql", "1.0.0")})
+
+	files := map[string]string{
+		"nginx-1.0.0":      "nginx-1.0.0.tgz",
+		"nginx-1.1.0-rc.1": "nginx-1.1.0-rc.1.tgz",
+		"redis-2.0.0":      "stable/redis-2.0.0.tgz",
+	}
+
+	testCases := []struct {
+		name    string
+		baseURL string
+		urls    map[string]string
+	}{
+		{
+			name: "without base url",
+			urls: map[string]string{
+				"nginx-1.0.0.tgz":        "nginx-1.0.0.tgz",
+				"nginx-1.1.0-rc.1.tgz":   "nginx-1.1.0-rc.1.tgz",
+				"stable/redis-2.0.0.tgz": "stable/redis-2.0.0.tgz",
+			},
+		},
+		{
+			name:    "base url with trailing slash",
+			baseURL: "https://charts.example.com/repo/",
+			urls: map[string]string{
+				"nginx-1.0.0.tgz":        "https://charts.example.com/repo/nginx-1.0.0.tgz",
+				"nginx-1.1.0-rc.1.tgz":   "https://charts.example.com/repo/nginx-1.1.0-rc.1.tgz",
+				"stable/redis-2.0.0.tgz": "https://charts.example.com/repo/stable/redis-2.0.0.tgz",
+			},
+		},
+		{
+			name:    "base url",
+			baseURL: "https://charts.example.com",
+			urls: map[string]string{
+				"nginx-1.0.0.tgz":        "https://charts.example.com/nginx-1.0.0.tgz",
+				"nginx-1.1.0-rc.1.tgz":   "https://charts.example.com/nginx-1.1.0-rc.1.tgz",
+				"stable/redis-2.0.0.tgz": "https://charts.example.com/stable/redis-2.0.0.tgz",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			index, err := IndexDirectory(dir, tc.baseURL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(index.Entries) != 2 || len(index.Entries["nginx"]) != 2 || len(index.Entries["redis"]) != 1 {
+				t.Fatalf("unexpected entries: %+v", index.Entries)
+			}
+			// a prerelease of a newer version is sorted first
+			if index.Entries["nginx"][0].Version != "1.1.0-rc.1" {
+				t.Errorf("unexpected order of nginx: %+v", index.Entries["nginx"])
+			}
+
+			for _, cv := range append(index.Entries["nginx"], index.Entries["redis"]...) {
+				file := files[cv.Name+"-"+cv.Version]
+				if !reflect.DeepEqual(cv.URLs, []string{tc.urls[file]}) {
+					t.Errorf("unexpected urls of %s: %v, expected %s", file, cv.URLs, tc.urls[file])
+				}
+				if cv.Digest != digests[file] {
+					t.Errorf("unexpected digest of %s: %s, expected %s", file, cv.Digest, digests[file])
+				}
+			}
+		})
+	}
+}
+
+func TestIndexDirectoryInvalidChart(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+	}{
+		{name: "no chart yaml", files: map[string]string{"nginx/values.yaml": "replicas: 1\n"}},
+		{name: "sub-chart only", files: map[string]string{"nginx/charts/common/Chart.yaml": chartYAML("common", "0.1.0")}},
+		{name: "no version", files: map[string]string{"nginx/Chart.yaml": "name: nginx\n"}},
+		{name: "invalid yaml", files: map[string]string{"nginx/Chart.yaml": "name: [nginx\n"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeChart(t, dir, "nginx.tgz", tc.files)
+			if _, err := IndexDirectory(dir, ""); err == nil {
+				t.Errorf("expected error, got nil")
+			}
+		})
+	}
+
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "broken.tgz"), []byte("not a gzip"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := LoadChartMetadata(filepath.Join(dir, "broken.tgz")); err == nil {
+		t.Errorf("expected error for a broken archive, got nil")
+	}
+}
+
+func TestLoadChartMetadata(t *testing.T) {
+	dir := t.TempDir()
+	writeChart(t, dir, "nginx.tgz", map[string]string{
+		"nginx/charts/common/Chart.yaml": chartYAML("common", "0.1.0"),
+		"nginx/Chart.yaml":               chartYAML("nginx", "1.2.3") + "appVersion: 1.21.0\ndescription: web server\n",
+	})
+
+	md, err := LoadChartMetadata(filepath.Join(dir, "nginx.tgz"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if md.Name != "nginx" || md.Version != "1.2.3" || md.AppVersion != "1.21.0" || md.Description != "web server" {
+		t.Errorf("unexpected metadata: %+v", md)
+	}
+}
+
+func TestDigestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	digest, err := DigestFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if digest != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("unexpected digest %s", digest)
+	}
+	if _, err = DigestFile(path + ".missing"); err == nil {
+		t.Errorf("expected error for a missing file, got nil")
+	}
+}
+
+func TestAdd(t *testing.T) {
+	testCases := []struct {
+		name     string
+		filename string
+		baseURL  string
+		url      string
+	}{
+		{name: "no base url", filename: "nginx-1.0.0.tgz", url: "nginx-1.0.0.tgz"},
+		{name: "base url", filename: "nginx-1.0.0.tgz", baseURL: "https://charts.example.com", url: "https://charts.example.com/nginx-1.0.0.tgz"},
+		{name: "trailing slash", filename: "nginx-1.0.0.tgz", baseURL: "https://charts.example.com/", url: "https://charts.example.com/nginx-1.0.0.tgz"},
+		{name: "sub-directory", filename: "./stable//nginx-1.0.0.tgz", baseURL: "https://charts.example.com/", url: "https://charts.example.com/stable/nginx-1.0.0.tgz"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			index := NewIndexFile()
+			if err := Add(index, v1.ChartMetadata{Name: "nginx", Version: "1.0.0"}, tc.filename, tc.baseURL, "sha"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			cvs := index.Entries["nginx"]
+			if len(cvs) != 1 || !reflect.DeepEqual(cvs[0].URLs, []string{tc.url}) || cvs[0].Digest != "sha" {
+				t.Errorf("unexpected entries: %+v", cvs)
+			}
+		})
+	}
+
+	index := NewIndexFile()
+	if err := Add(index, v1.ChartMetadata{Name: "nginx"}, "nginx.tgz", "", ""); err == nil {
+		t.Errorf("expected error for a chart without version, got nil")
+	}
+	// the first added chart version is kept
+	Add(index, v1.ChartMetadata{Name: "nginx", Version: "1.0.0"}, "a/nginx-1.0.0.tgz", "", "a")
+	Add(index, v1.ChartMetadata{Name: "nginx", Version: "1.0.0"}, "b/nginx-1.0.0.tgz", "", "b")
+	if cvs := index.Entries["nginx"]; len(cvs) != 1 || cvs[0].Digest != "a" {
+		t.Errorf("unexpected entries: %+v", cvs)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dst := NewIndexFile()
+	Add(dst, v1.ChartMetadata{Name: "nginx", Version: "1.0.0"}, "nginx-1.0.0.tgz", "https://dst.example.com", "dst")
+	src := NewIndexFile()
+	Add(src, v1.ChartMetadata{Name: "nginx", Version: "1.0.0"}, "nginx-1.0.0.tgz", "https://src.example.com", "src")
+	Add(src, v1.ChartMetadata{Name: "nginx", Version: "2.0.0"}, "nginx-2.0.0.tgz", "https://src.example.com", "src")
+	Add(src, v1.ChartMetadata{Name: "redis", Version: "1.0.0"}, "redis-1.0.0.tgz", "https://src.example.com", "src")
+
+	Merge(dst, src)
+	SortEntries(dst)
+
+	expected := map[string][]string{
+		"nginx": {"2.0.0/src", "1.0.0/dst"},
+		"redis": {"1.0.0/src"},
+	}
+	got := map[string][]string{}
+	for name, cvs := range dst.Entries {
+		for _, cv := range cvs {
+			got[name] = append(got[name], cv.Version+"/"+cv.Digest)
+		}
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	// an index without entries is initialized
+	empty := &v1.IndexFile{}
+	Merge(empty, src)
+	if len(empty.Entries) != 2 {
+		t.Errorf("unexpected entries: %+v", empty.Entries)
+	}
+}
+
+func TestSortEntries(t *testing.T) {
+	versions := []string{"1.0.0-alpha", "0.9.0", "v1.2.0", "1.0.0", "latest", "1.10.0", "1.0.0-beta.2", "1.0.0-beta.11", "dev"}
+	index := NewIndexFile()
+	for _, version := range versions {
+		index.Entries["nginx"] = append(index.Entries["nginx"], v1.ChartVersion{ChartMetadata: v1.ChartMetadata{Name: "nginx", Version: version}})
+	}
+
+	SortEntries(index)
+	var got []string
+	for _, cv := range index.Entries["nginx"] {
+		got = append(got, cv.Version)
+	}
+	// the invalid versions are sorted after the valid ones
+	expected := []string{"1.10.0", "v1.2.0", "1.0.0", "1.0.0-beta.11", "1.0.0-beta.2", "1.0.0-alpha", "0.9.0", "latest", "dev"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestVersionGreater(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected bool
+	}{
+		{a: "1.0.1", b: "1.0.0", expected: true},
+		{a: "1.0.0", b: "1.0.1", expected: false},
+		{a: "1.10.0", b: "1.9.0", expected: true},
+		{a: "1.0.0", b: "1.0.0-rc.1", expected: true},
+		{a: "1.0.0-rc.1", b: "1.0.0", expected: false},
+		{a: "1.0.0-rc.2", b: "1.0.0-rc.10", expected: false},
+		{a: "v2.0.0", b: "1.0.0", expected: true},
+		{a: "1.0.0", b: "1.0.0", expected: false},
+		{a: "0.0.1", b: "latest", expected: true},
+		{a: "latest", b: "0.0.1", expected: false},
+		{a: "latest", b: "dev", expected: true},
+	}
+
+	for _, tc := range testCases {
+		if got := versionGreater(tc.a, tc.b); got != tc.expected {
+			t.Errorf("versionGreater(%q, %q): expected %v, got %v", tc.a, tc.b, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+)
+
+// The storage drivers of helm, they are the values of HELM_DRIVER.
+const (
+	DriverSecret    = "secret"
+	DriverConfigMap = "configmap"
+	DriverMemory    = "memory"
+	DriverSQL       = "sql"
+)
+
+const (
+	// releaseKey is the key of the release payload in the record.
+	releaseKey = "release"
+
+	labelOwner = "owner"
+	labelName  = "name"
+	ownerHelm  = "helm"
+)
+
+// Reader reads the release records of a helm storage driver.
+type Reader interface {
+	// List returns all the revisions of the releases in the namespace, or in
+	// all namespaces if the namespace is empty. The selector filters the
+	// releases by the labels of the records.
+	List(ctx context.Context, namespace string, selector labels.Selector) ([]*v1.ReleaseStatus, error)
+	// History returns all the revisions of a release, oldest first.
+	History(ctx context.Context, namespace string, name string) ([]*v1.ReleaseStatus, error)
+}
+
+// NewReader returns the reader of the storage driver backed by kubernetes, the
+// secret driver is used if the driver is empty. Use NewSQL for the sql driver.
+func NewReader(driver string, client kubernetes.Interface) (Reader, error) {
+	switch driver {
+	case "", DriverSecret:
+		return NewSecrets(client), nil
+	case DriverConfigMap:
+		return NewConfigMaps(client), nil
+	case DriverMemory:
+		return nil, fmt.Errorf("the memory storage driver lives in the helm process and can not be read")
+	case DriverSQL:
+		return nil, fmt.Errorf("the sql storage driver is not backed by kubernetes, use NewSQL instead")
+	}
+
+	return nil, fmt.Errorf("unsupported storage driver %q", driver)
+}
+
+// secrets reads the releases stored in secrets, which is the default driver of helm.
+type secrets struct {
+	client kubernetes.Interface
+}
+
+// NewSecrets returns a reader of the secret driver.
+func NewSecrets(client kubernetes.Interface) Reader {
+	return &secrets{client: client}
+}
+
+func (s *secrets) List(ctx context.Context, namespace string, selector labels.Selector) ([]*v1.ReleaseStatus, error) {
+	sel, err := recordSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return s.list(ctx, namespace, sel)
+}
+
+func (s *secrets) History(ctx context.Context, namespace string, name string) ([]*v1.ReleaseStatus, error) {
+	sel, err := recordSelector(nil, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.list(ctx, namespace, sel)
+}
+
+func (s *secrets) list(ctx context.Context, namespace string, selector labels.Selector) ([]*v1.ReleaseStatus, error) {
+	list, err := s.client.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("list release secrets failed %v", err)
+	}
+
+	records := make([]*v1.ReleaseStatus, 0, len(list.Items))
+	for _, item := range list.Items {
+		rs, err := decodeRelease(string(item.Data[releaseKey]))
+		if err != nil {
+			klog.Warningf("skip the release secret %s/%s: %v", item.Namespace, item.Name, err)
+			continue
+		}
+		records = append(records, rs)
+	}
+
+	return sortRecords(records), nil
+}
+
+// configMaps reads the releases stored in config maps.
+type configMaps struct {
+	client kubernetes.Interface
+}
+
+// NewConfigMaps returns a reader of the configmap driver.
+func NewConfigMaps(client kubernetes.Interface) Reader {
+	return &configMaps{client: client}
+}
+
+func (c *configMaps) List(ctx context.Context, namespace string, selector labels.Selector) ([]*v1.ReleaseStatus, error) {
+	sel, err := recordSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return c.list(ctx, namespace, sel)
+}
+
+func (c *configMaps) History(ctx context.Context, namespace string, name string) ([]*v1.ReleaseStatus, error) {
+	sel, err := recordSelector(nil, name)
+	if err != nil {
+		return nil, err
+	}
+	return c.list(ctx, namespace, sel)
+}
+
+func (c *configMaps) list(ctx context.Context, namespace string, selector labels.Selector) ([]*v1.ReleaseStatus, error) {
+	list, err := c.client.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("list release configmaps failed %v", err)
+	}
+
+	records := make([]*v1.ReleaseStatus, 0, len(list.Items))
+	for _, item := range list.Items {
+		rs, err := decodeRelease(item.Data[releaseKey])
+		if err != nil {
+			klog.Warningf("skip the release configmap %s/%s: %v", item.Namespace, item.Name, err)
+			continue
+		}
+		records = append(records, rs)
+	}
+
+	return sortRecords(records), nil
+}
+
+// recordSelector returns the selector of the release records owned by helm,
+// narrowed to the release if the name is given.
+func recordSelector(selector labels.Selector, name ...string) (labels.Selector, error) {
+	if selector == nil {
+		selector = labels.Everything()
+	}
+
+	owner, err := labels.NewRequirement(labelOwner, selection.Equals, []string{ownerHelm})
+	if err != nil {
+		return nil, err
+	}
+	selector = selector.Add(*owner)
+	if len(name) != 0 {
+		byName, err := labels.NewRequirement(labelName, selection.Equals, name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid release name %q: %v", name[0], err)
+		}
+		selector = selector.Add(*byName)
+	}
+
+	return selector, nil
+}
+
+// sortRecords sorts the records by namespace, name and revision.
+func sortRecords(records []*v1.ReleaseStatus) []*v1.ReleaseStatus {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Namespace != records[j].Namespace {
+			return records[i].Namespace < records[j].Namespace
+		}
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+		return records[i].Version < records[j].Version
+	})
+
+	return records
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+)
+
+var magicGzip = []byte{0x1f, 0x8b, 0x08}
+
+// decodeRelease decodes the payload of a release record, which is the base64
+// encoded, and usually gzipped, json of the release.
+func decodeRelease(data string) (*v1.ReleaseStatus, error) {
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode release record failed %v", err)
+	}
+
+	if len(b) > 3 && bytes.Equal(b[0:3], magicGzip) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("decompress release record failed %v", err)
+		}
+		defer r.Close()
+		if b, err = ioutil.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("decompress release record failed %v", err)
+		}
+	}
+
+	var rs v1.ReleaseStatus
+	if err = json.Unmarshal(b, &rs); err != nil {
+		return nil, fmt.Errorf("unmarshal to release failed %v", err)
+	}
+
+	return &rs, nil
+}
+
+// encodeRelease encodes a release the same way as helm does.
+func encodeRelease(rs *v1.ReleaseStatus) (string, error) {
+	b, err := json.Marshal(rs)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err = w.Write(b); err != nil {
+		return "", err
+	}
+	if err = w.Close(); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
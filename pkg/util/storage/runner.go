@@ -0,0 +1,298 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	utiltrace "k8s.io/utils/trace"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	"github.com/caoyingjunz/client-helm/internal/releaseutil"
+	utilhelm "github.com/caoyingjunz/client-helm/pkg/util/helm"
+)
+
+// runner implements utilhelm.Interface, the releases are read from the storage
+// of helm and the other operations are run by the wrapped runner. The outputs
+// are the same as the ones printed by helm.
+type runner struct {
+	utilhelm.Interface
+
+	reader Reader
+}
+
+// New returns a helm runner which reads the releases by the reader, rather
+// than by running helm, the other operations are run by the given runner.
+func New(helm utilhelm.Interface, reader Reader) utilhelm.Interface {
+	return &runner{
+		Interface: helm,
+		reader:    reader,
+	}
+}
+
+// History returns the revisions of a release as printed by `helm history -o json`.
+func (runner *runner) History(ctx context.Context, namespace string, name string, opts metav1.HistoryOptions) ([]byte, error) {
+	trace := utiltrace.New("storage history")
+	defer trace.LogIfLong(time.Second)
+
+	records, err := runner.history(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Max > 0 && len(records) > opts.Max {
+		records = records[len(records)-opts.Max:]
+	}
+
+	history := make([]v1.ReleaseRevision, 0, len(records))
+	for _, rs := range records {
+		r := releaseutil.FromStatus(rs)
+		revision := v1.ReleaseRevision{
+			Revision:   rs.Version,
+			Status:     r.Status,
+			Chart:      r.Chart,
+			AppVersion: r.AppVersion,
+		}
+		if rs.Info != nil {
+			revision.Updated = rs.Info.LastDeployed
+			revision.Description = rs.Info.Description
+		}
+		history = append(history, revision)
+	}
+
+	return json.Marshal(history)
+}
+
+// Get returns the release as printed by `helm list --filter ^NAME$ --all -o json`.
+func (runner *runner) Get(ctx context.Context, namespace string, name string) ([]byte, error) {
+	trace := utiltrace.New("storage get")
+	defer trace.LogIfLong(time.Second)
+
+	return runner.List(ctx, namespace, metav1.ListOptions{
+		Filter: fmt.Sprintf("^%s$", regexp.QuoteMeta(name)),
+		All:    true,
+	})
+}
+
+// Status returns the release as printed by `helm status -o json`.
+func (runner *runner) Status(ctx context.Context, namespace string, name string, opts metav1.StatusOptions) ([]byte, error) {
+	trace := utiltrace.New("storage status")
+	defer trace.LogIfLong(time.Second)
+
+	rs, err := runner.revision(ctx, namespace, name, opts.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(rs)
+}
+
+// GetValues returns the values of a release as printed by `helm get values -o json`.
+func (runner *runner) GetValues(ctx context.Context, namespace string, name string, opts metav1.GetValuesOptions) ([]byte, error) {
+	rs, err := runner.revision(ctx, namespace, name, opts.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	values := rs.Config
+	if opts.AllValues && rs.Chart != nil {
+		values = releaseutil.MergeValues(rs.Chart.Values, rs.Config)
+	}
+
+	return json.Marshal(values)
+}
+
+// GetManifest returns the manifest of a release as printed by `helm get manifest`.
+func (runner *runner) GetManifest(ctx context.Context, namespace string, name string, opts metav1.GetManifestOptions) ([]byte, error) {
+	rs, err := runner.revision(ctx, namespace, name, opts.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(rs.Manifest + "\n"), nil
+}
+
+// GetNotes returns the notes of a release as printed by `helm get notes`.
+func (runner *runner) GetNotes(ctx context.Context, namespace string, name string, opts metav1.GetNotesOptions) ([]byte, error) {
+	rs, err := runner.revision(ctx, namespace, name, opts.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	if rs.Info == nil || len(rs.Info.Notes) == 0 {
+		return []byte{}, nil
+	}
+	return []byte(fmt.Sprintf("NOTES:\n%s\n", rs.Info.Notes)), nil
+}
+
+// GetHooks returns the hooks of a release as printed by `helm get hooks`.
+func (runner *runner) GetHooks(ctx context.Context, namespace string, name string, opts metav1.GetHooksOptions) ([]byte, error) {
+	rs, err := runner.revision(ctx, namespace, name, opts.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, hook := range rs.Hooks {
+		fmt.Fprintf(&buf, "---\n# Source: %s\n%s\n", hook.Path, hook.Manifest)
+	}
+	return buf.Bytes(), nil
+}
+
+// List returns the releases as printed by `helm list -o json`. Like helm, only
+// the latest revision of each release is taken, and only the deployed and the
+// failed releases are listed unless any state is given.
+func (runner *runner) List(ctx context.Context, namespace string, opts metav1.ListOptions) ([]byte, error) {
+	trace := utiltrace.New("storage list")
+	defer trace.LogIfLong(time.Second)
+
+	if opts.Max < 0 || opts.Offset < 0 {
+		return nil, fmt.Errorf("max and offset can not be negative when list release")
+	}
+	var filter *regexp.Regexp
+	if len(opts.Filter) != 0 {
+		var err error
+		if filter, err = regexp.Compile(opts.Filter); err != nil {
+			return nil, fmt.Errorf("invalid filter %q: %v", opts.Filter, err)
+		}
+	}
+	var selector labels.Selector
+	if len(opts.Selector) != 0 {
+		var err error
+		if selector, err = labels.Parse(opts.Selector); err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %v", opts.Selector, err)
+		}
+	}
+
+	records, err := runner.reader.List(ctx, namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	states := releaseutil.ListStates(opts)
+	var items []*v1.ReleaseStatus
+	for _, rs := range latestRevisions(records, !onlySuperseded(opts)) {
+		if rs.Info == nil || !states[rs.Info.Status] {
+			continue
+		}
+		if filter != nil && !filter.MatchString(rs.Name) {
+			continue
+		}
+		items = append(items, rs)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if opts.SortByDate {
+			return items[i].Info.LastDeployed.Before(items[j].Info.LastDeployed.Time)
+		}
+		return items[i].Name < items[j].Name
+	})
+	if opts.Reverse {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	if opts.Offset > len(items) {
+		opts.Offset = len(items)
+	}
+	items = items[opts.Offset:]
+	if opts.Max > 0 && len(items) > opts.Max {
+		items = items[:opts.Max]
+	}
+
+	releases := make([]v1.Release, 0, len(items))
+	for _, rs := range items {
+		releases = append(releases, *releaseutil.FromStatus(rs))
+	}
+
+	return json.Marshal(releases)
+}
+
+// history returns the revisions of a release, oldest first.
+func (runner *runner) history(ctx context.Context, namespace string, name string) ([]*v1.ReleaseStatus, error) {
+	if len(name) == 0 {
+		return nil, fmt.Errorf("name can not be empty when get release")
+	}
+
+	records, err := runner.reader.History(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, utilhelm.NewReleaseNotFound(name)
+	}
+
+	return records, nil
+}
+
+// revision returns the given revision of a release, zero means the latest one.
+func (runner *runner) revision(ctx context.Context, namespace string, name string, revision int) (*v1.ReleaseStatus, error) {
+	records, err := runner.history(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if revision == 0 {
+		return records[len(records)-1], nil
+	}
+	for _, rs := range records {
+		if rs.Version == revision {
+			return rs, nil
+		}
+	}
+
+	return nil, utilhelm.NewReleaseNotFound(name)
+}
+
+// latestRevisions returns the latest revision of each release if latest is
+// set, or all the revisions otherwise.
+func latestRevisions(records []*v1.ReleaseStatus, latest bool) []*v1.ReleaseStatus {
+	if !latest {
+		return records
+	}
+
+	index := make(map[string]int)
+	var releases []*v1.ReleaseStatus
+	for _, rs := range records {
+		key := rs.Namespace + "/" + rs.Name
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(releases)
+			releases = append(releases, rs)
+			continue
+		}
+		if rs.Version > releases[i].Version {
+			releases[i] = rs
+		}
+	}
+
+	return releases
+}
+
+// onlySuperseded returns true if only the superseded releases are listed,
+// they are never the latest revisions.
+func onlySuperseded(opts metav1.ListOptions) bool {
+	return opts.Superseded && !opts.All && !opts.Deployed && !opts.Failed && !opts.Pending && !opts.Uninstalled && !opts.Uninstalling
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kubemetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	utilhelm "github.com/caoyingjunz/client-helm/pkg/util/helm"
+	helmtesting "github.com/caoyingjunz/client-helm/pkg/util/helm/testing"
+)
+
+func newRecord(namespace, name string, version int, status v1.ReleasePhase) *v1.ReleaseStatus {
+	return &v1.ReleaseStatus{
+		Name:      name,
+		Namespace: namespace,
+		Version:   version,
+		Info: &v1.ReleaseInfo{
+			LastDeployed: metav1.NewTime(time.Date(2021, 10, version, 0, 0, 0, 0, time.UTC)),
+			Description:  fmt.Sprintf("revision %d", version),
+			Status:       status,
+			Notes:        "hello",
+		},
+		Chart: &v1.Chart{
+			Metadata: &v1.ChartMetadata{Name: "nginx", Version: fmt.Sprintf("1.0.%d", version), AppVersion: "1.21"},
+			Values:   map[string]interface{}{"replicas": float64(1), "image": map[string]interface{}{"tag": "stable"}},
+		},
+		Config:   map[string]interface{}{"image": map[string]interface{}{"tag": "latest"}},
+		Manifest: "kind: Service",
+		Hooks:    []v1.Hook{{Name: "test", Path: "nginx/templates/test.yaml", Manifest: "kind: Pod"}},
+	}
+}
+
+func newSecret(t *testing.T, rs *v1.ReleaseStatus) runtime.Object {
+	data, err := encodeRelease(rs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	labels := map[string]string{
+		"owner":   "helm",
+		"name":    rs.Name,
+		"version": strconv.Itoa(rs.Version),
+	}
+	if rs.Info != nil {
+		labels["status"] = string(rs.Info.Status)
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: kubemetav1.ObjectMeta{
+			Name:      fmt.Sprintf("sh.helm.release.v1.%s.v%d", rs.Name, rs.Version),
+			Namespace: rs.Namespace,
+			Labels:    labels,
+		},
+		Type: "helm.sh/release.v1",
+		Data: map[string][]byte{"release": []byte(data)},
+	}
+}
+
+func newRunner(t *testing.T, objects ...runtime.Object) (utilhelm.Interface, *helmtesting.FakeHelm) {
+	helm := helmtesting.NewFakeHelm()
+	reader, err := NewReader(DriverSecret, fake.NewSimpleClientset(objects...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return New(helm, reader), helm
+}
+
+func TestList(t *testing.T) {
+	ctx := context.TODO()
+	runner, _ := newRunner(t,
+		newSecret(t, newRecord("web", "nginx", 1, v1.ReleasePhaseSuperseded)),
+		newSecret(t, newRecord("web", "nginx", 2, v1.ReleasePhaseDeployed)),
+		newSecret(t, newRecord("web", "broken", 1, v1.ReleasePhaseFailed)),
+		newSecret(t, newRecord("db", "mysql", 1, v1.ReleasePhaseUninstalled)),
+		newSecret(t, newRecord("db", "redis", 3, v1.ReleasePhaseDeployed)),
+		// not owned by helm
+		&corev1.Secret{ObjectMeta: kubemetav1.ObjectMeta{Name: "token", Namespace: "web"}},
+	)
+
+	testCases := []struct {
+		name      string
+		namespace string
+		opts      metav1.ListOptions
+		expected  []string
+	}{
+		{name: "default states", expected: []string{"broken.1", "nginx.2", "redis.3"}},
+		{name: "namespace", namespace: "web", expected: []string{"broken.1", "nginx.2"}},
+		{name: "all", opts: metav1.ListOptions{All: true}, expected: []string{"broken.1", "mysql.1", "nginx.2", "redis.3"}},
+		{name: "superseded", opts: metav1.ListOptions{Superseded: true}, expected: []string{"nginx.1"}},
+		{name: "uninstalled", opts: metav1.ListOptions{Uninstalled: true}, expected: []string{"mysql.1"}},
+		{name: "filter", opts: metav1.ListOptions{Filter: "^n"}, expected: []string{"nginx.2"}},
+		{name: "selector", opts: metav1.ListOptions{Selector: "name!=nginx"}, expected: []string{"broken.1", "redis.3"}},
+		{name: "date reverse", opts: metav1.ListOptions{SortByDate: true, Reverse: true}, expected: []string{"redis.3", "nginx.2", "broken.1"}},
+		{name: "paging", opts: metav1.ListOptions{Max: 1, Offset: 1}, expected: []string{"nginx.2"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := runner.List(ctx, tc.namespace, tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var releases []v1.Release
+			if err = json.Unmarshal(out, &releases); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			names := []string{}
+			for _, r := range releases {
+				names = append(names, r.Name+"."+r.Revision)
+			}
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, names)
+			}
+		})
+	}
+}
+
+func TestReleaseDetails(t *testing.T) {
+	ctx := context.TODO()
+	runner, _ := newRunner(t,
+		newSecret(t, newRecord("web", "nginx", 1, v1.ReleasePhaseSuperseded)),
+		newSecret(t, newRecord("web", "nginx", 2, v1.ReleasePhaseDeployed)),
+	)
+
+	out, err := runner.Get(ctx, "web", "nginx")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var releases []v1.Release
+	if err = json.Unmarshal(out, &releases); err != nil || len(releases) != 1 || releases[0].Chart != "nginx-1.0.2" {
+		t.Errorf("unexpected release %s: %v", out, err)
+	}
+
+	out, err = runner.Status(ctx, "web", "nginx", metav1.StatusOptions{Revision: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var rs v1.ReleaseStatus
+	if err = json.Unmarshal(out, &rs); err != nil || rs.Version != 1 || rs.Info.Status != v1.ReleasePhaseSuperseded {
+		t.Errorf("unexpected status %s: %v", out, err)
+	}
+
+	out, err = runner.History(ctx, "web", "nginx", metav1.HistoryOptions{Max: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var history []v1.ReleaseRevision
+	if err = json.Unmarshal(out, &history); err != nil || len(history) != 1 || history[0].Revision != 2 || history[0].Description != "revision 2" {
+		t.Errorf("unexpected history %s: %v", out, err)
+	}
+
+	out, err = runner.GetValues(ctx, "web", "nginx", metav1.GetValuesOptions{AllValues: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != `{"image":{"tag":"latest"},"replicas":1}` {
+		t.Errorf("unexpected values %s", out)
+	}
+
+	if out, _ = runner.GetNotes(ctx, "web", "nginx", metav1.GetNotesOptions{}); string(out) != "NOTES:\nhello\n" {
+		t.Errorf("unexpected notes %q", out)
+	}
+	if out, _ = runner.GetHooks(ctx, "web", "nginx", metav1.GetHooksOptions{}); string(out) != "---\n# Source: nginx/templates/test.yaml\nkind: Pod\n" {
+		t.Errorf("unexpected hooks %q", out)
+	}
+
+	// the release is got in any state
+	pending, _ := newRunner(t, newSecret(t, newRecord("web", "nginx", 1, v1.ReleasePhasePendingInstall)))
+	out, err = pending.Get(ctx, "web", "nginx")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = json.Unmarshal(out, &releases); err != nil || len(releases) != 1 || releases[0].Status != string(v1.ReleasePhasePendingInstall) {
+		t.Errorf("unexpected pending release %s: %v", out, err)
+	}
+
+	if _, err = runner.Status(ctx, "web", "nginx", metav1.StatusOptions{Revision: 3}); !utilhelm.IsReleaseNotFound(err) {
+		t.Errorf("expected release not found error, got %v", err)
+	}
+	if _, err = runner.Status(ctx, "db", "nginx", metav1.StatusOptions{}); !utilhelm.IsReleaseNotFound(err) {
+		t.Errorf("expected release not found error, got %v", err)
+	}
+}
+
+func TestReleaseWithoutInfo(t *testing.T) {
+	ctx := context.TODO()
+	rs := newRecord("web", "nginx", 1, v1.ReleasePhaseDeployed)
+	rs.Info = nil
+	runner, _ := newRunner(t, newSecret(t, rs))
+
+	out, err := runner.History(ctx, "web", "nginx", metav1.HistoryOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var history []v1.ReleaseRevision
+	if err = json.Unmarshal(out, &history); err != nil || len(history) != 1 || history[0].Revision != 1 || len(history[0].Description) != 0 || !history[0].Updated.IsZero() {
+		t.Errorf("unexpected history %s: %v", out, err)
+	}
+
+	out, err = runner.GetNotes(ctx, "web", "nginx", metav1.GetNotesOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out) != 0 {
+		t.Errorf("expected no notes, got %q", out)
+	}
+}
+
+func TestConfigMaps(t *testing.T) {
+	rs := newRecord("web", "nginx", 1, v1.ReleasePhaseDeployed)
+	data, err := encodeRelease(rs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: kubemetav1.ObjectMeta{
+			Name:      "sh.helm.release.v1.nginx.v1",
+			Namespace: "web",
+			Labels:    map[string]string{"owner": "helm", "name": "nginx"},
+		},
+		Data: map[string]string{"release": data},
+	})
+
+	reader, err := NewReader(DriverConfigMap, client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	records, err := reader.History(context.TODO(), "web", "nginx")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 1 || !reflect.DeepEqual(records[0].Config, rs.Config) {
+		t.Errorf("unexpected records: %+v", records)
+	}
+
+	if _, err = NewReader("unknown", client); err == nil {
+		t.Errorf("expected error for unknown driver, got nil")
+	}
+}
+
+func TestDelegation(t *testing.T) {
+	runner, helm := newRunner(t)
+	helm.SetResponse("Install", []byte(`{"name":"nginx"}`), nil)
+
+	out, err := runner.Install(context.TODO(), "web", "nginx", metav1.InstallOptions{ChartReference: "bitnami/nginx"})
+	if err != nil || string(out) != `{"name":"nginx"}` {
+		t.Errorf("unexpected install result %s: %v", out, err)
+	}
+	calls := helm.GetCalls()
+	if len(calls) != 1 || calls[0].Method != "Install" || calls[0].Namespace != "web" {
+		t.Errorf("unexpected calls: %+v", calls)
+	}
+}
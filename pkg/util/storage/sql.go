@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+)
+
+const (
+	// DefaultSQLDriverName is the database/sql driver name of PostgreSQL,
+	// which is the only database supported by the sql driver of helm.
+	DefaultSQLDriverName = "postgres"
+
+	sqlReleaseTableName = "releases_v1"
+)
+
+// sqlReader reads the releases stored by the sql driver of helm, the queries
+// follow the schema created by helm and use the PostgreSQL placeholders.
+type sqlReader struct {
+	db *sql.DB
+}
+
+// NewSQL returns a reader of the sql driver, the db must be connected to the
+// database of helm, i.e. the HELM_DRIVER_SQL_CONNECTION_STRING.
+func NewSQL(db *sql.DB) Reader {
+	return &sqlReader{db: db}
+}
+
+func (s *sqlReader) List(ctx context.Context, namespace string, selector labels.Selector) ([]*v1.ReleaseStatus, error) {
+	query := fmt.Sprintf("SELECT body, name, status, version FROM %s WHERE owner = $1", sqlReleaseTableName)
+	args := []interface{}{ownerHelm}
+	if len(namespace) != 0 {
+		query += " AND namespace = $2"
+		args = append(args, namespace)
+	}
+
+	return s.query(ctx, selector, query, args...)
+}
+
+func (s *sqlReader) History(ctx context.Context, namespace string, name string) ([]*v1.ReleaseStatus, error) {
+	query := fmt.Sprintf("SELECT body, name, status, version FROM %s WHERE owner = $1 AND name = $2", sqlReleaseTableName)
+	args := []interface{}{ownerHelm, name}
+	if len(namespace) != 0 {
+		query += " AND namespace = $3"
+		args = append(args, namespace)
+	}
+
+	return s.query(ctx, nil, query, args...)
+}
+
+// query runs the query and decodes the releases, the selector is matched
+// against the labels helm keeps in the columns.
+func (s *sqlReader) query(ctx context.Context, selector labels.Selector, query string, args ...interface{}) ([]*v1.ReleaseStatus, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query release records failed %v", err)
+	}
+	defer rows.Close()
+
+	var records []*v1.ReleaseStatus
+	for rows.Next() {
+		var body, name, status string
+		var version int
+		if err = rows.Scan(&body, &name, &status, &version); err != nil {
+			return nil, fmt.Errorf("scan release record failed %v", err)
+		}
+		if selector != nil && !selector.Matches(labels.Set{
+			labelOwner: ownerHelm,
+			labelName:  name,
+			"status":   status,
+			"version":  strconv.Itoa(version),
+		}) {
+			continue
+		}
+
+		rs, err := decodeRelease(body)
+		if err != nil {
+			klog.Warningf("skip the release record %s.v%d: %v", name, version, err)
+			continue
+		}
+		records = append(records, rs)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("query release records failed %v", err)
+	}
+
+	return sortRecords(records), nil
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+)
+
+// memSQLDriver is an in-memory stand-in of the database of the sql storage
+// driver, it only understands the queries of the sql reader.
+type memSQLDriver struct {
+	lock   sync.Mutex
+	tables map[string][]map[string]driver.Value
+}
+
+var memSQL = &memSQLDriver{tables: map[string][]map[string]driver.Value{}}
+
+func init() {
+	sql.Register("memsql", memSQL)
+}
+
+var selectPattern = regexp.MustCompile(`^SELECT (.+) FROM releases_v1 WHERE (.+)$`)
+
+func (d *memSQLDriver) insert(t *testing.T, dsn string, rs *v1.ReleaseStatus) {
+	body, err := encodeRelease(rs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.tables[dsn] = append(d.tables[dsn], map[string]driver.Value{
+		"key":       fmt.Sprintf("sh.helm.release.v1.%s.v%d", rs.Name, rs.Version),
+		"type":      "helm.sh/release.v1",
+		"body":      body,
+		"name":      rs.Name,
+		"namespace": rs.Namespace,
+		"version":   int64(rs.Version),
+		"status":    string(rs.Info.Status),
+		"owner":     "helm",
+	})
+}
+
+func (d *memSQLDriver) Open(dsn string) (driver.Conn, error) {
+	return &memSQLConn{driver: d, dsn: dsn}, nil
+}
+
+type memSQLConn struct {
+	driver *memSQLDriver
+	dsn    string
+}
+
+func (c *memSQLConn) Prepare(query string) (driver.Stmt, error) {
+	match := selectPattern.FindStringSubmatch(query)
+	if match == nil {
+		return nil, fmt.Errorf("unsupported query %q", query)
+	}
+	return &memSQLStmt{conn: c, columns: strings.Split(match[1], ", "), conditions: strings.Split(match[2], " AND ")}, nil
+}
+
+func (c *memSQLConn) Close() error { return nil }
+
+func (c *memSQLConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("transactions are not supported")
+}
+
+type memSQLStmt struct {
+	conn       *memSQLConn
+	columns    []string
+	conditions []string
+}
+
+func (s *memSQLStmt) Close() error  { return nil }
+func (s *memSQLStmt) NumInput() int { return len(s.conditions) }
+
+func (s *memSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("exec is not supported")
+}
+
+func (s *memSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.conn.driver.lock.Lock()
+	defer s.conn.driver.lock.Unlock()
+
+	rows := &memSQLRows{columns: s.columns}
+	for _, row := range s.conn.driver.tables[s.conn.dsn] {
+		matched := true
+		for _, condition := range s.conditions {
+			var column string
+			var index int
+			if _, err := fmt.Sscanf(condition, "%s = $%d", &column, &index); err != nil {
+				return nil, fmt.Errorf("unsupported condition %q", condition)
+			}
+			if row[column] != args[index-1] {
+				matched = false
+			}
+		}
+		if !matched {
+			continue
+		}
+		values := make([]driver.Value, 0, len(s.columns))
+		for _, column := range s.columns {
+			values = append(values, row[column])
+		}
+		rows.values = append(rows.values, values)
+	}
+
+	return rows, nil
+}
+
+type memSQLRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *memSQLRows) Columns() []string { return r.columns }
+func (r *memSQLRows) Close() error      { return nil }
+
+func (r *memSQLRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestSQL(t *testing.T) {
+	ctx := context.TODO()
+	memSQL.insert(t, t.Name(), newRecord("web", "nginx", 1, v1.ReleasePhaseSuperseded))
+	memSQL.insert(t, t.Name(), newRecord("web", "nginx", 2, v1.ReleasePhaseDeployed))
+	memSQL.insert(t, t.Name(), newRecord("db", "nginx", 1, v1.ReleasePhaseDeployed))
+	memSQL.insert(t, t.Name(), newRecord("db", "redis", 1, v1.ReleasePhaseFailed))
+
+	db, err := sql.Open("memsql", t.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer db.Close()
+	reader := NewSQL(db)
+
+	versions := func(records []*v1.ReleaseStatus) []string {
+		out := []string{}
+		for _, rs := range records {
+			out = append(out, rs.Namespace+"/"+rs.Name+"."+strconv.Itoa(rs.Version))
+		}
+		return out
+	}
+
+	records, err := reader.History(ctx, "web", "nginx")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"web/nginx.1", "web/nginx.2"}; !reflect.DeepEqual(versions(records), expected) {
+		t.Errorf("expected %v, got %v", expected, versions(records))
+	}
+	if !reflect.DeepEqual(records[1].Config, newRecord("web", "nginx", 2, v1.ReleasePhaseDeployed).Config) {
+		t.Errorf("unexpected config: %v", records[1].Config)
+	}
+
+	testCases := []struct {
+		name      string
+		namespace string
+		selector  string
+		expected  []string
+	}{
+		{name: "all namespaces", expected: []string{"db/nginx.1", "db/redis.1", "web/nginx.1", "web/nginx.2"}},
+		{name: "namespace", namespace: "db", expected: []string{"db/nginx.1", "db/redis.1"}},
+		{name: "selector", selector: "status=deployed", expected: []string{"db/nginx.1", "web/nginx.2"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			selector, err := labels.Parse(tc.selector)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			records, err := reader.List(ctx, tc.namespace, selector)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(versions(records), tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, versions(records))
+			}
+		})
+	}
+
+	if _, err = NewReader(DriverSQL, nil); err == nil {
+		t.Errorf("expected error for the sql driver, got nil")
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watch
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+)
+
+// ListFunc lists the releases to watch.
+type ListFunc func(ctx context.Context) ([]v1.Release, error)
+
+// poller implements Interface by listing the releases periodically, helm has
+// no watch of its own.
+type poller struct {
+	interval time.Duration
+	list     ListFunc
+	result   chan Event
+	cancel   context.CancelFunc
+
+	// known are the releases of the last list keyed by namespace/name.
+	known map[string]v1.Release
+}
+
+// NewPoller returns a watch which lists the releases every interval and emits
+// the changes since the previous list. The releases of the first list are
+// emitted as added, a release is modified if any field of its summary, such
+// as the revision or the status, changed, and deleted once it is no longer
+// listed. A failed list is emitted as an error event and ends the watch. The
+// watch also ends when the ctx is done.
+func NewPoller(ctx context.Context, interval time.Duration, list ListFunc) Interface {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &poller{
+		interval: interval,
+		list:     list,
+		result:   make(chan Event),
+		cancel:   cancel,
+		known:    map[string]v1.Release{},
+	}
+	go p.run(ctx)
+
+	return p
+}
+
+func (p *poller) Stop() {
+	p.cancel()
+}
+
+func (p *poller) ResultChan() <-chan Event {
+	return p.result
+}
+
+func (p *poller) run(ctx context.Context) {
+	defer close(p.result)
+	defer p.cancel()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		if !p.poll(ctx) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll lists the releases and emits the changes, it returns false if the watch
+// should end.
+func (p *poller) poll(ctx context.Context) bool {
+	releases, err := p.list(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			p.send(ctx, Event{Type: Error, Err: err})
+		}
+		return false
+	}
+
+	current := make(map[string]v1.Release, len(releases))
+	for i := range releases {
+		r := releases[i]
+		key := r.Namespace + "/" + r.Name
+		current[key] = r
+
+		old, ok := p.known[key]
+		switch {
+		case !ok:
+			if !p.send(ctx, Event{Type: Added, Object: &r}) {
+				return false
+			}
+		case old != r:
+			if !p.send(ctx, Event{Type: Modified, Object: &r}) {
+				return false
+			}
+		}
+	}
+
+	var deleted []string
+	for key := range p.known {
+		if _, ok := current[key]; !ok {
+			deleted = append(deleted, key)
+		}
+	}
+	sort.Strings(deleted)
+	for _, key := range deleted {
+		r := p.known[key]
+		if !p.send(ctx, Event{Type: Deleted, Object: &r}) {
+			return false
+		}
+	}
+	p.known = current
+
+	return true
+}
+
+func (p *poller) send(ctx context.Context, event Event) bool {
+	select {
+	case p.result <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
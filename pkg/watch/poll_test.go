@@ -0,0 +1,92 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watch
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+)
+
+func TestPoller(t *testing.T) {
+	snapshots := [][]v1.Release{
+		{
+			{Name: "nginx", Namespace: "web", Revision: "1", Status: "deployed"},
+			{Name: "redis", Namespace: "db", Revision: "1", Status: "deployed"},
+		},
+		// unchanged
+		{
+			{Name: "nginx", Namespace: "web", Revision: "1", Status: "deployed"},
+			{Name: "redis", Namespace: "db", Revision: "1", Status: "deployed"},
+		},
+		{
+			{Name: "nginx", Namespace: "web", Revision: "2", Status: "deployed"},
+			{Name: "mysql", Namespace: "db", Revision: "1", Status: "pending-install"},
+		},
+	}
+
+	polls := 0
+	w := NewPoller(context.TODO(), time.Millisecond, func(ctx context.Context) ([]v1.Release, error) {
+		if polls == len(snapshots) {
+			return nil, fmt.Errorf("helm failed")
+		}
+		polls++
+		return snapshots[polls-1], nil
+	})
+	defer w.Stop()
+
+	var events []string
+	for event := range w.ResultChan() {
+		events = append(events, event.String())
+	}
+	expected := []string{
+		"ADDED web/nginx revision 1",
+		"ADDED db/redis revision 1",
+		"MODIFIED web/nginx revision 2",
+		"ADDED db/mysql revision 1",
+		"DELETED db/redis revision 1",
+		"ERROR: helm failed",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %v, got %v", expected, events)
+	}
+}
+
+func TestPollerStop(t *testing.T) {
+	w := NewPoller(context.TODO(), time.Hour, func(ctx context.Context) ([]v1.Release, error) {
+		return []v1.Release{{Name: "nginx", Namespace: "web"}}, nil
+	})
+
+	if event := <-w.ResultChan(); event.Type != Added {
+		t.Errorf("expected added event, got %v", event)
+	}
+	w.Stop()
+	select {
+	case _, ok := <-w.ResultChan():
+		if ok {
+			t.Errorf("expected the result channel to be closed")
+		}
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Errorf("timed out waiting for the watch to stop")
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watch
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+)
+
+// Interface can be implemented by anything that knows how to watch and report
+// changes of the releases.
+type Interface interface {
+	// Stop stops watching. Will close the channel returned by ResultChan().
+	// Releases any resources used by the watch.
+	Stop()
+
+	// ResultChan returns a chan which will receive all the events. If an error
+	// occurs or Stop() is called, the implementation will close this channel
+	// and release any resources used by the watch.
+	ResultChan() <-chan Event
+}
+
+// EventType defines the possible types of events.
+type EventType string
+
+const (
+	Added    EventType = "ADDED"
+	Modified EventType = "MODIFIED"
+	Deleted  EventType = "DELETED"
+	Error    EventType = "ERROR"
+)
+
+// DefaultChanSize is the buffer size of the result channel of FakeWatcher.
+const DefaultChanSize int32 = 100
+
+// Event represents a single event to a watched release.
+type Event struct {
+	Type EventType
+
+	// Object is:
+	//  * If Type is Added or Modified: the new state of the release.
+	//  * If Type is Deleted: the state of the release immediately before deletion.
+	//  * If Type is Error: nil.
+	Object *v1.Release
+
+	// Err is the error which ends the watch if Type is Error.
+	Err error
+}
+
+func (e Event) String() string {
+	if e.Type == Error {
+		return fmt.Sprintf("%s: %v", e.Type, e.Err)
+	}
+	if e.Object == nil {
+		return string(e.Type)
+	}
+
+	return fmt.Sprintf("%s %s/%s revision %s", e.Type, e.Object.Namespace, e.Object.Name, e.Object.Revision)
+}
+
+// FakeWatcher lets you test anything that consumes a watch.Interface. The
+// result channel is buffered, so that the events can be sent before they are
+// consumed, it panics if the buffer is full.
+type FakeWatcher struct {
+	result  chan Event
+	stopped bool
+	sync.Mutex
+}
+
+// NewFake returns a FakeWatcher with a buffer of DefaultChanSize events.
+func NewFake() *FakeWatcher {
+	return &FakeWatcher{
+		result: make(chan Event, DefaultChanSize),
+	}
+}
+
+// Stop implements Interface.Stop().
+func (f *FakeWatcher) Stop() {
+	f.Lock()
+	defer f.Unlock()
+	if !f.stopped {
+		close(f.result)
+		f.stopped = true
+	}
+}
+
+func (f *FakeWatcher) IsStopped() bool {
+	f.Lock()
+	defer f.Unlock()
+	return f.stopped
+}
+
+func (f *FakeWatcher) ResultChan() <-chan Event {
+	return f.result
+}
+
+// Add sends an add event.
+func (f *FakeWatcher) Add(obj *v1.Release) {
+	f.Action(Added, obj)
+}
+
+// Modify sends a modify event.
+func (f *FakeWatcher) Modify(obj *v1.Release) {
+	f.Action(Modified, obj)
+}
+
+// Delete sends a delete event.
+func (f *FakeWatcher) Delete(lastValue *v1.Release) {
+	f.Action(Deleted, lastValue)
+}
+
+// Error sends an Error event.
+func (f *FakeWatcher) Error(err error) {
+	f.send(Event{Type: Error, Err: err})
+}
+
+// Action sends an event of the requested type, for table-based testing.
+func (f *FakeWatcher) Action(action EventType, obj *v1.Release) {
+	f.send(Event{Type: action, Object: obj})
+}
+
+func (f *FakeWatcher) send(event Event) {
+	f.Lock()
+	defer f.Unlock()
+	if f.stopped {
+		return
+	}
+
+	select {
+	case f.result <- event:
+	default:
+		panic(fmt.Errorf("channel full"))
+	}
+}
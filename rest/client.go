@@ -17,25 +17,232 @@ limitations under the License.
 package rest
 
 import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/utils/exec"
 
 	utilhelm "github.com/caoyingjunz/client-helm/pkg/util/helm"
+	"github.com/caoyingjunz/client-helm/pkg/util/storage"
+)
+
+// The environment variables of the storage driver of helm.
+const (
+	envHelmDriver              = "HELM_DRIVER"
+	envHelmDriverSQLConnection = "HELM_DRIVER_SQL_CONNECTION_STRING"
 )
 
 type Interface interface {
 	GetClient() utilhelm.Interface
+	// Close releases the resources held by the client, such as the temporary
+	// kubeconfig file.
+	Close() error
 }
 
 type HelmClient struct {
 	Client utilhelm.Interface
+
+	// kubeConfigFile is the temporary file of the KubeConfigData, it is owned
+	// by the client and removed by Close.
+	kubeConfigFile string
+	// db is the database of the sql storage driver opened for the native
+	// storage, it is closed by Close.
+	db *sql.DB
+}
+
+func HelmClientFor(c Config) (*HelmClient, error) {
+	if len(c.KubeConfig) != 0 && len(c.KubeConfigData) != 0 {
+		return nil, fmt.Errorf("kubeconfig and kubeconfig data can not be set at the same time")
+	}
+	// The driver and the connection string of helm and of the native storage
+	// are resolved once, so that they always agree.
+	var err error
+	if c.StorageDriver, err = resolveEnv(c.Env, envHelmDriver, c.StorageDriver); err != nil {
+		return nil, err
+	}
+	if c.SQLConnectionString, err = resolveEnv(c.Env, envHelmDriverSQLConnection, c.SQLConnectionString); err != nil {
+		return nil, err
+	}
+	switch c.StorageDriver {
+	case "", storage.DriverSecret, storage.DriverConfigMap, storage.DriverMemory, storage.DriverSQL:
+	default:
+		return nil, fmt.Errorf("unsupported storage driver %q", c.StorageDriver)
+	}
+
+	hc := &HelmClient{}
+	kubeConfig := c.KubeConfig
+	if len(c.KubeConfigData) != 0 {
+		if hc.kubeConfigFile, err = writeTempKubeConfig(c.KubeConfigData); err != nil {
+			return nil, err
+		}
+		kubeConfig = hc.kubeConfigFile
+	}
+
+	env := withEnv(c.Env, envHelmDriver, c.StorageDriver)
+	env = withEnv(env, envHelmDriverSQLConnection, c.SQLConnectionString)
+
+	hc.Client = utilhelm.New(exec.New(), utilhelm.Config{
+		Binary:                c.HelmBinary,
+		KubeConfig:            kubeConfig,
+		KubeContext:           c.KubeContext,
+		APIServer:             c.APIServer,
+		BearerToken:           c.BearerToken,
+		CAFile:                c.CAFile,
+		ImpersonateUser:       c.ImpersonateUser,
+		ImpersonateGroups:     c.ImpersonateGroups,
+		InsecureSkipTLSVerify: c.InsecureSkipTLSVerify,
+		RegistryConfig:        c.RegistryConfig,
+		RepositoryConfig:      c.RepositoryConfig,
+		RepositoryCache:       c.RepositoryCache,
+		BurstLimit:            c.BurstLimit,
+		Env:                   env,
+		Debug:                 c.Debug,
+		WarningHandler:        c.WarningHandler,
+	})
+	if c.NativeStorage {
+		reader, err := hc.storageReaderFor(c, kubeConfig)
+		if err != nil {
+			hc.Close()
+			return nil, err
+		}
+		hc.Client = storage.New(hc.Client, reader)
+	}
+
+	return hc, nil
 }
 
-func HelmClientFor(c Config) *HelmClient {
-	return &HelmClient{
-		Client: utilhelm.New(exec.New(), c.KubeConfig),
+// storageReaderFor returns the reader of the helm storage, the kubernetes
+// client is built from the same settings passed to helm.
+func (hc *HelmClient) storageReaderFor(c Config, kubeConfig string) (storage.Reader, error) {
+	switch c.StorageDriver {
+	case storage.DriverSQL:
+		return hc.sqlReaderFor(c)
+	case storage.DriverMemory:
+		return nil, fmt.Errorf("the memory storage driver lives in the helm process and can not be read natively")
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeConfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: c.KubeContext}
+	overrides.ClusterInfo.Server = c.APIServer
+	overrides.ClusterInfo.CertificateAuthority = c.CAFile
+	overrides.ClusterInfo.InsecureSkipTLSVerify = c.InsecureSkipTLSVerify
+	overrides.AuthInfo.Token = c.BearerToken
+	overrides.AuthInfo.Impersonate = c.ImpersonateUser
+	overrides.AuthInfo.ImpersonateGroups = c.ImpersonateGroups
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("build kubernetes config for the native storage failed %v", err)
+	}
+	if c.BurstLimit > 0 {
+		config.Burst = c.BurstLimit
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.NewReader(c.StorageDriver, client)
+}
+
+// sqlReaderFor opens the database of the sql storage driver, the connection
+// string is the one passed to helm.
+func (hc *HelmClient) sqlReaderFor(c Config) (storage.Reader, error) {
+	connection := c.SQLConnectionString
+	if len(connection) == 0 {
+		return nil, fmt.Errorf("the sql connection string is required by the native storage")
+	}
+	driverName := c.SQLDriverName
+	if len(driverName) == 0 {
+		driverName = storage.DefaultSQLDriverName
+	}
+
+	db, err := sql.Open(driverName, connection)
+	if err != nil {
+		return nil, fmt.Errorf("open the sql storage failed %v", err)
 	}
+	hc.db = db
+
+	return storage.NewSQL(db), nil
 }
 
 func (hc *HelmClient) GetClient() utilhelm.Interface {
 	return hc.Client
 }
+
+// Close removes the temporary kubeconfig file and closes the database of the
+// sql storage driver, if any.
+func (hc *HelmClient) Close() error {
+	if hc.db != nil {
+		if err := hc.db.Close(); err != nil {
+			return err
+		}
+		hc.db = nil
+	}
+	if len(hc.kubeConfigFile) == 0 {
+		return nil
+	}
+
+	if err := os.Remove(hc.kubeConfigFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	hc.kubeConfigFile = ""
+	return nil
+}
+
+// writeTempKubeConfig writes the kubeconfig to a temporary file which is only
+// readable by the current user, since it may hold credentials.
+func writeTempKubeConfig(data []byte) (string, error) {
+	f, err := ioutil.TempFile("", "client-helm-kubeconfig-")
+	if err != nil {
+		return "", fmt.Errorf("create temporary kubeconfig failed %v", err)
+	}
+	defer f.Close()
+
+	if err = f.Chmod(0600); err == nil {
+		_, err = f.Write(data)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("write temporary kubeconfig failed %v", err)
+	}
+
+	return f.Name(), nil
+}
+
+// resolveEnv returns the value of a helm variable, which is set by the Env, by
+// the value of the Config, or by the environment of the current process in
+// that order. An Env which conflicts with the value of the Config is rejected.
+func resolveEnv(env map[string]string, key, value string) (string, error) {
+	if envValue, ok := env[key]; ok {
+		if len(value) != 0 && value != envValue {
+			return "", fmt.Errorf("%s %q in the env conflicts with %q in the config", key, envValue, value)
+		}
+		return envValue, nil
+	}
+	if len(value) != 0 {
+		return value, nil
+	}
+
+	return os.Getenv(key), nil
+}
+
+// withEnv returns the env with the variable set, unless the value is empty or
+// the variable is already in the env. The env of the caller is not modified.
+func withEnv(env map[string]string, key, value string) map[string]string {
+	if _, ok := env[key]; ok || len(value) == 0 {
+		return env
+	}
+
+	out := make(map[string]string, len(env)+1)
+	for k, v := range env {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
@@ -15,3 +15,103 @@ limitations under the License.
 */
 
 package rest
+
+import (
+	"os"
+	"testing"
+)
+
+func TestHelmClientForStorageDriver(t *testing.T) {
+	testCases := []struct {
+		name      string
+		config    Config
+		expectErr bool
+	}{
+		{name: "default", config: Config{}},
+		{name: "sql", config: Config{StorageDriver: "sql", SQLConnectionString: "host=localhost"}},
+		{name: "unknown driver", config: Config{StorageDriver: "etcd"}, expectErr: true},
+		{name: "native memory", config: Config{StorageDriver: "memory", NativeStorage: true}, expectErr: true},
+		{name: "native sql without connection", config: Config{StorageDriver: "sql", NativeStorage: true}, expectErr: true},
+		{name: "env driver", config: Config{StorageDriver: "configmap", Env: map[string]string{"HELM_DRIVER": "configmap"}}},
+		{name: "conflicting env driver", config: Config{StorageDriver: "secret", Env: map[string]string{"HELM_DRIVER": "configmap"}}, expectErr: true},
+		{name: "unknown env driver", config: Config{Env: map[string]string{"HELM_DRIVER": "etcd"}}, expectErr: true},
+		{name: "native memory from env", config: Config{NativeStorage: true, Env: map[string]string{"HELM_DRIVER": "memory"}}, expectErr: true},
+		{
+			name: "conflicting env connection",
+			config: Config{
+				StorageDriver:       "sql",
+				SQLConnectionString: "host=localhost",
+				Env:                 map[string]string{"HELM_DRIVER_SQL_CONNECTION_STRING": "host=remote"},
+			},
+			expectErr: true,
+		},
+		{
+			name:      "native sql with unregistered driver",
+			config:    Config{StorageDriver: "sql", NativeStorage: true, SQLConnectionString: "host=localhost", SQLDriverName: "unregistered"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hc, err := HelmClientFor(tc.config)
+			if tc.expectErr {
+				if err == nil {
+					hc.Close()
+					t.Errorf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err = hc.Close(); err != nil {
+				t.Errorf("unexpected close error: %v", err)
+			}
+		})
+	}
+}
+
+func TestResolveEnv(t *testing.T) {
+	old, ok := os.LookupEnv("HELM_DRIVER")
+	os.Setenv("HELM_DRIVER", "configmap")
+	defer func() {
+		if ok {
+			os.Setenv("HELM_DRIVER", old)
+		} else {
+			os.Unsetenv("HELM_DRIVER")
+		}
+	}()
+
+	testCases := []struct {
+		name      string
+		env       map[string]string
+		value     string
+		expected  string
+		expectErr bool
+	}{
+		{name: "process", expected: "configmap"},
+		{name: "config", value: "sql", expected: "sql"},
+		{name: "env", env: map[string]string{"HELM_DRIVER": "secret"}, expected: "secret"},
+		{name: "env and config", env: map[string]string{"HELM_DRIVER": "sql"}, value: "sql", expected: "sql"},
+		{name: "conflict", env: map[string]string{"HELM_DRIVER": "secret"}, value: "sql", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := resolveEnv(tc.env, "HELM_DRIVER", tc.value)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if value != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, value)
+			}
+		})
+	}
+}
@@ -16,8 +16,77 @@ limitations under the License.
 
 package rest
 
+import (
+	utilhelm "github.com/caoyingjunz/client-helm/pkg/util/helm"
+)
+
 // Config holds the common attributes that can be passed to a helm client on
 // initialization.
 type Config struct {
+	// HelmBinary is the path of the helm binary, the "helm" in $PATH is used
+	// if not set.
+	HelmBinary string
+
+	// KubeConfig is the path of the kubeconfig file.
 	KubeConfig string
+	// KubeConfigData is the content of a kubeconfig, it takes the place of
+	// KubeConfig. The client writes it to a temporary file for helm, which is
+	// removed when the client is closed.
+	KubeConfigData []byte
+	// KubeContext is the name of the kubeconfig context to use.
+	KubeContext string
+	// APIServer is the address and the port for the Kubernetes API server.
+	APIServer string
+	// BearerToken is the bearer token used for authentication.
+	BearerToken string
+	// CAFile is the certificate authority file for the Kubernetes API server connection.
+	CAFile string
+	// ImpersonateUser is the username to impersonate for the operation.
+	ImpersonateUser string
+	// ImpersonateGroups are the groups to impersonate for the operation.
+	ImpersonateGroups []string
+	// InsecureSkipTLSVerify skips the verification of the Kubernetes API
+	// server's certificate.
+	InsecureSkipTLSVerify bool
+
+	// RegistryConfig is the path to the registry config file.
+	RegistryConfig string
+	// RepositoryConfig is the path to the file containing repository names and URLs.
+	RepositoryConfig string
+	// RepositoryCache is the path to the file containing cached repository indexes.
+	RepositoryCache string
+
+	// BurstLimit is the client-side default throttling limit, zero means the helm default.
+	BurstLimit int
+	// Env are the extra environment variables of helm, such as HELM_CACHE_HOME
+	// and HELM_DRIVER.
+	Env map[string]string
+	// Debug enables the verbose output of helm.
+	Debug bool
+
+	// NativeStorage reads the releases directly from the storage of helm
+	// through client-go, rather than by running helm. It applies to get,
+	// status, history and list, the other operations still run helm.
+	NativeStorage bool
+	// StorageDriver is the storage driver of helm, "secret" by default,
+	// "configmap", "memory" or "sql". It is passed to helm by HELM_DRIVER.
+	// It may be set by HELM_DRIVER in Env instead, or is taken from the
+	// HELM_DRIVER of the current process if neither is set, a HELM_DRIVER in
+	// Env which differs from it is rejected. The memory driver lives in the
+	// helm process, so it can not be used with NativeStorage.
+	StorageDriver string
+	// SQLConnectionString is the connection string of the sql storage driver.
+	// It is passed to helm by HELM_DRIVER_SQL_CONNECTION_STRING, and is
+	// resolved from Env and the current process as the StorageDriver.
+	SQLConnectionString string
+	// SQLDriverName is the database/sql driver used by NativeStorage to read
+	// the sql storage driver, "postgres" by default. The driver must be
+	// registered by the caller, e.g. by importing github.com/lib/pq.
+	SQLDriverName string
+
+	// WarningHandler handles the warnings printed by helm, such as
+	// "WARNING: Kubernetes configuration file is group-readable".
+	// The warnings are logged if it is not set, use utilhelm.NoWarnings{} to
+	// suppress them.
+	WarningHandler utilhelm.WarningHandler
 }
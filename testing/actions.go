@@ -0,0 +1,409 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"fmt"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+)
+
+// The resources the actions are performed on.
+const (
+	ReleasesResource = "releases"
+	ReposResource    = "repos"
+	ChartsResource   = "charts"
+)
+
+// The verbs of the actions, they follow the helm commands.
+const (
+	VerbCreate   = "create"
+	VerbInstall  = "install"
+	VerbTemplate = "template"
+	VerbUpgrade  = "upgrade"
+	VerbRollback = "rollback"
+	VerbDelete   = "delete"
+	VerbGet      = "get"
+	VerbList     = "list"
+	VerbWatch    = "watch"
+	VerbAdd      = "add"
+	VerbIndex    = "index"
+	VerbRemove   = "remove"
+	VerbUpdate   = "update"
+	VerbSearch   = "search"
+	VerbShow     = "show"
+)
+
+// The subresources of the releases, they follow the `helm get` subcommands.
+const (
+	SubresourceStatus   = "status"
+	SubresourceHistory  = "history"
+	SubresourceValues   = "values"
+	SubresourceManifest = "manifest"
+	SubresourceNotes    = "notes"
+	SubresourceHooks    = "hooks"
+)
+
+func NewCreateAction(namespace string, opts metav1.CreateOptions) GenericActionImpl {
+	action := GenericActionImpl{}
+	action.Verb = VerbCreate
+	action.Resource = ReleasesResource
+	action.Namespace = namespace
+	action.Value = opts
+
+	return action
+}
+
+func NewInstallAction(namespace, name string, opts metav1.InstallOptions) InstallActionImpl {
+	action := InstallActionImpl{}
+	action.Verb = VerbInstall
+	action.Resource = ReleasesResource
+	action.Namespace = namespace
+	action.Name = name
+	action.InstallOptions = opts
+
+	return action
+}
+
+func NewTemplateAction(namespace, name string, opts metav1.InstallOptions) InstallActionImpl {
+	action := NewInstallAction(namespace, name, opts)
+	action.Verb = VerbTemplate
+
+	return action
+}
+
+func NewUpgradeAction(namespace, name string, opts metav1.UpgradeOptions) UpgradeActionImpl {
+	action := UpgradeActionImpl{}
+	action.Verb = VerbUpgrade
+	action.Resource = ReleasesResource
+	action.Namespace = namespace
+	action.Name = name
+	action.UpgradeOptions = opts
+
+	return action
+}
+
+func NewRollbackAction(namespace, name string, revision int, opts metav1.RollbackOptions) RollbackActionImpl {
+	action := RollbackActionImpl{}
+	action.Verb = VerbRollback
+	action.Resource = ReleasesResource
+	action.Namespace = namespace
+	action.Name = name
+	action.Revision = revision
+	action.RollbackOptions = opts
+
+	return action
+}
+
+func NewDeleteAction(namespace, name string, opts metav1.DeleteOptions) DeleteActionImpl {
+	action := DeleteActionImpl{}
+	action.Verb = VerbDelete
+	action.Resource = ReleasesResource
+	action.Namespace = namespace
+	action.Name = name
+	action.DeleteOptions = opts
+
+	return action
+}
+
+func NewGetAction(namespace, name string, opts metav1.GetOptions) GetActionImpl {
+	action := GetActionImpl{}
+	action.Verb = VerbGet
+	action.Resource = ReleasesResource
+	action.Namespace = namespace
+	action.Name = name
+	action.Value = opts
+
+	return action
+}
+
+// NewGetSubresourceAction returns an action which reads the details of a
+// release, such as the status, the history or the values. The value is the
+// options of the call.
+func NewGetSubresourceAction(namespace, subresource, name string, value interface{}) GetActionImpl {
+	action := GetActionImpl{}
+	action.Verb = VerbGet
+	action.Resource = ReleasesResource
+	action.Subresource = subresource
+	action.Namespace = namespace
+	action.Name = name
+	action.Value = value
+
+	return action
+}
+
+func NewListAction(namespace string, opts metav1.ListOptions) ListActionImpl {
+	action := ListActionImpl{}
+	action.Verb = VerbList
+	action.Resource = ReleasesResource
+	action.Namespace = namespace
+	action.ListOptions = opts
+
+	return action
+}
+
+func NewWatchAction(namespace string, opts metav1.ListOptions) WatchActionImpl {
+	action := WatchActionImpl{}
+	action.Verb = VerbWatch
+	action.Resource = ReleasesResource
+	action.Namespace = namespace
+	action.ListOptions = opts
+
+	return action
+}
+
+func NewRepoAddAction(repo v1.Repo, opts metav1.RepoAddOptions) RepoAddActionImpl {
+	action := RepoAddActionImpl{}
+	action.Verb = VerbAdd
+	action.Resource = ReposResource
+	action.Repo = repo
+	action.RepoAddOptions = opts
+
+	return action
+}
+
+// NewGenericAction returns an action on the repositories or the charts, the
+// name is the target of the action, such as the search keyword, and the value
+// is the options or the names of the call.
+func NewGenericAction(verb, resource, subresource, name string, value interface{}) GenericActionImpl {
+	action := GenericActionImpl{}
+	action.Verb = verb
+	action.Resource = resource
+	action.Subresource = subresource
+	action.Name = name
+	action.Value = value
+
+	return action
+}
+
+type Action interface {
+	GetNamespace() string
+	GetVerb() string
+	GetResource() string
+	GetSubresource() string
+	Matches(verb, resource string) bool
+}
+
+type GenericAction interface {
+	Action
+	GetName() string
+	GetValue() interface{}
+}
+
+type GetAction interface {
+	Action
+	GetName() string
+	GetValue() interface{}
+}
+
+type ListAction interface {
+	Action
+	GetListOptions() metav1.ListOptions
+}
+
+type WatchAction interface {
+	Action
+	GetListOptions() metav1.ListOptions
+}
+
+type InstallAction interface {
+	Action
+	GetName() string
+	GetInstallOptions() metav1.InstallOptions
+}
+
+type UpgradeAction interface {
+	Action
+	GetName() string
+	GetUpgradeOptions() metav1.UpgradeOptions
+}
+
+type RollbackAction interface {
+	Action
+	GetName() string
+	GetRevision() int
+	GetRollbackOptions() metav1.RollbackOptions
+}
+
+type DeleteAction interface {
+	Action
+	GetName() string
+	GetDeleteOptions() metav1.DeleteOptions
+}
+
+type RepoAddAction interface {
+	Action
+	GetRepo() v1.Repo
+	GetRepoAddOptions() metav1.RepoAddOptions
+}
+
+type ActionImpl struct {
+	Namespace   string
+	Verb        string
+	Resource    string
+	Subresource string
+}
+
+func (a ActionImpl) GetNamespace() string {
+	return a.Namespace
+}
+
+func (a ActionImpl) GetVerb() string {
+	return a.Verb
+}
+
+func (a ActionImpl) GetResource() string {
+	return a.Resource
+}
+
+func (a ActionImpl) GetSubresource() string {
+	return a.Subresource
+}
+
+func (a ActionImpl) Matches(verb, resource string) bool {
+	return verb == a.Verb && resource == a.Resource
+}
+
+func (a ActionImpl) String() string {
+	if len(a.Subresource) == 0 {
+		return fmt.Sprintf("%s %s in %q", a.Verb, a.Resource, a.Namespace)
+	}
+
+	return fmt.Sprintf("%s %s/%s in %q", a.Verb, a.Resource, a.Subresource, a.Namespace)
+}
+
+type GenericActionImpl struct {
+	ActionImpl
+	Name  string
+	Value interface{}
+}
+
+func (a GenericActionImpl) GetName() string {
+	return a.Name
+}
+
+func (a GenericActionImpl) GetValue() interface{} {
+	return a.Value
+}
+
+type GetActionImpl struct {
+	ActionImpl
+	Name  string
+	Value interface{}
+}
+
+func (a GetActionImpl) GetName() string {
+	return a.Name
+}
+
+func (a GetActionImpl) GetValue() interface{} {
+	return a.Value
+}
+
+type ListActionImpl struct {
+	ActionImpl
+	ListOptions metav1.ListOptions
+}
+
+func (a ListActionImpl) GetListOptions() metav1.ListOptions {
+	return a.ListOptions
+}
+
+type WatchActionImpl struct {
+	ActionImpl
+	ListOptions metav1.ListOptions
+}
+
+func (a WatchActionImpl) GetListOptions() metav1.ListOptions {
+	return a.ListOptions
+}
+
+type InstallActionImpl struct {
+	ActionImpl
+	Name           string
+	InstallOptions metav1.InstallOptions
+}
+
+func (a InstallActionImpl) GetName() string {
+	return a.Name
+}
+
+func (a InstallActionImpl) GetInstallOptions() metav1.InstallOptions {
+	return a.InstallOptions
+}
+
+type UpgradeActionImpl struct {
+	ActionImpl
+	Name           string
+	UpgradeOptions metav1.UpgradeOptions
+}
+
+func (a UpgradeActionImpl) GetName() string {
+	return a.Name
+}
+
+func (a UpgradeActionImpl) GetUpgradeOptions() metav1.UpgradeOptions {
+	return a.UpgradeOptions
+}
+
+type RollbackActionImpl struct {
+	ActionImpl
+	Name            string
+	Revision        int
+	RollbackOptions metav1.RollbackOptions
+}
+
+func (a RollbackActionImpl) GetName() string {
+	return a.Name
+}
+
+func (a RollbackActionImpl) GetRevision() int {
+	return a.Revision
+}
+
+func (a RollbackActionImpl) GetRollbackOptions() metav1.RollbackOptions {
+	return a.RollbackOptions
+}
+
+type DeleteActionImpl struct {
+	ActionImpl
+	Name          string
+	DeleteOptions metav1.DeleteOptions
+}
+
+func (a DeleteActionImpl) GetName() string {
+	return a.Name
+}
+
+func (a DeleteActionImpl) GetDeleteOptions() metav1.DeleteOptions {
+	return a.DeleteOptions
+}
+
+type RepoAddActionImpl struct {
+	ActionImpl
+	Repo           v1.Repo
+	RepoAddOptions metav1.RepoAddOptions
+}
+
+func (a RepoAddActionImpl) GetRepo() v1.Repo {
+	return a.Repo
+}
+
+func (a RepoAddActionImpl) GetRepoAddOptions() metav1.RepoAddOptions {
+	return a.RepoAddOptions
+}
@@ -0,0 +1,866 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	"github.com/caoyingjunz/client-helm/internal/releaseutil"
+	utilhelm "github.com/caoyingjunz/client-helm/pkg/util/helm"
+	"github.com/caoyingjunz/client-helm/pkg/watch"
+)
+
+const defaultNamespace = "default"
+
+// ReleaseTracker keeps track of the releases and the repositories for a fake
+// clientset. Each install, upgrade and rollback records a new revision of the
+// release, like the helm storage does. It is intended to be used to implement
+// fake clientsets, the returned objects are copies and can be modified freely.
+type ReleaseTracker interface {
+	// Add seeds a release as if it had been installed, the revision, the status
+	// and the chart are parsed from the summary.
+	Add(release v1.Release) error
+
+	// Install records the first revision of a release, it returns the release
+	// with the generated name if GenerateName or NameTemplate is set.
+	Install(ns, name string, opts metav1.InstallOptions) (*v1.ReleaseStatus, error)
+
+	// Upgrade records a new revision of a release, the release is installed if
+	// it does not exist and opts.Install is set.
+	Upgrade(ns, name string, opts metav1.UpgradeOptions) (*v1.ReleaseStatus, error)
+
+	// Rollback records a new revision of a release with the chart and the values
+	// of the given revision, a zero revision means the previous one.
+	Rollback(ns, name string, revision int, opts metav1.RollbackOptions) (*v1.ReleaseStatus, error)
+
+	// Delete uninstalls a release, the history is kept if opts.KeepHistory is set.
+	Delete(ns, name string, opts metav1.DeleteOptions) (*v1.ReleaseStatus, error)
+
+	// Get returns a revision of a release, a zero revision means the latest one.
+	Get(ns, name string, revision int) (*v1.ReleaseStatus, error)
+
+	// History returns all the revisions of a release, oldest first.
+	History(ns, name string) ([]v1.ReleaseStatus, error)
+
+	// List returns the latest revision of the releases in the namespace, or in
+	// all namespaces if ns is empty.
+	List(ns string) ([]v1.ReleaseStatus, error)
+
+	// Watch watches the releases in the namespace, or in all namespaces if ns
+	// is empty. Each change of a release is emitted with its latest revision,
+	// an uninstall with the history kept is emitted as modified.
+	Watch(ns string) (watch.Interface, error)
+
+	// AddRepo adds a chart repository, it fails if the name is in use by a
+	// repository with a different url.
+	AddRepo(repo v1.Repo) error
+
+	// ListRepos returns the chart repositories, sorted by name.
+	ListRepos() []v1.Repo
+
+	// RemoveRepo removes a chart repository.
+	RemoveRepo(name string) error
+}
+
+// ObjectReaction returns a ReactionFunc that applies the release actions to
+// the tracker and returns the objects expected by the typed clients. The
+// actions on the charts, the template and the index actions are not handled.
+func ObjectReaction(tracker ReleaseTracker) ReactionFunc {
+	return func(action Action) (bool, interface{}, error) {
+		switch action := action.(type) {
+		case InstallActionImpl:
+			if action.GetVerb() != VerbInstall {
+				return false, nil, nil
+			}
+			rs, err := tracker.Install(action.GetNamespace(), action.GetName(), action.GetInstallOptions())
+			if err != nil {
+				return true, nil, err
+			}
+			return true, releaseutil.FromStatus(rs), nil
+
+		case UpgradeActionImpl:
+			rs, err := tracker.Upgrade(action.GetNamespace(), action.GetName(), action.GetUpgradeOptions())
+			if err != nil {
+				return true, nil, err
+			}
+			return true, releaseutil.FromStatus(rs), nil
+
+		case RollbackActionImpl:
+			_, err := tracker.Rollback(action.GetNamespace(), action.GetName(), action.GetRevision(), action.GetRollbackOptions())
+			return true, nil, err
+
+		case DeleteActionImpl:
+			rs, err := tracker.Delete(action.GetNamespace(), action.GetName(), action.GetDeleteOptions())
+			if err != nil {
+				return true, nil, err
+			}
+			// helm does not print the uninstalled release, so as the client
+			// only the name, the namespace and the status are returned
+			r := &v1.Release{Name: rs.Name, Namespace: rs.Namespace}
+			if !action.GetDeleteOptions().DryRun {
+				r.Status = string(v1.ReleasePhaseUninstalled)
+			}
+			return true, &v1.UninstallReleaseResponse{Release: r}, nil
+
+		case GetActionImpl:
+			return getReaction(tracker, action)
+
+		case ListActionImpl:
+			return listReaction(tracker, action)
+
+		case WatchActionImpl:
+			w, err := tracker.Watch(action.GetNamespace())
+			return true, w, err
+
+		case RepoAddActionImpl:
+			return true, nil, tracker.AddRepo(action.GetRepo())
+
+		case GenericActionImpl:
+			return repoReaction(tracker, action)
+		}
+
+		return false, nil, nil
+	}
+}
+
+func getReaction(tracker ReleaseTracker, action GetActionImpl) (bool, interface{}, error) {
+	ns, name := action.GetNamespace(), action.GetName()
+
+	switch action.GetSubresource() {
+	case "":
+		// the release is got in any state, like helm list --all
+		rs, err := tracker.Get(ns, name, 0)
+		if err != nil {
+			return true, nil, err
+		}
+		return true, releaseutil.FromStatus(rs), nil
+
+	case SubresourceStatus:
+		opts, _ := action.GetValue().(metav1.StatusOptions)
+		rs, err := tracker.Get(ns, name, opts.Revision)
+		if err != nil {
+			return true, nil, err
+		}
+		return true, rs, nil
+
+	case SubresourceHistory:
+		opts, _ := action.GetValue().(metav1.HistoryOptions)
+		revisions, err := tracker.History(ns, name)
+		if err != nil {
+			return true, nil, err
+		}
+		if opts.Max > 0 && len(revisions) > opts.Max {
+			revisions = revisions[len(revisions)-opts.Max:]
+		}
+		history := &v1.ReleaseHistory{Items: make([]v1.ReleaseRevision, 0, len(revisions))}
+		for _, rs := range revisions {
+			r := releaseutil.FromStatus(&rs)
+			history.Items = append(history.Items, v1.ReleaseRevision{
+				Revision:    rs.Version,
+				Updated:     rs.Info.LastDeployed,
+				Status:      r.Status,
+				Chart:       r.Chart,
+				AppVersion:  r.AppVersion,
+				Description: rs.Info.Description,
+			})
+		}
+		return true, history, nil
+
+	case SubresourceValues:
+		opts, _ := action.GetValue().(metav1.GetValuesOptions)
+		rs, err := tracker.Get(ns, name, opts.Revision)
+		if err != nil {
+			return true, nil, err
+		}
+		values := rs.Config
+		if opts.AllValues {
+			values = releaseutil.MergeValues(rs.Chart.Values, rs.Config)
+		}
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+		return true, values, nil
+
+	case SubresourceManifest:
+		opts, _ := action.GetValue().(metav1.GetManifestOptions)
+		rs, err := tracker.Get(ns, name, opts.Revision)
+		if err != nil {
+			return true, nil, err
+		}
+		return true, &v1.ReleaseManifest{Manifest: rs.Manifest}, nil
+
+	case SubresourceNotes:
+		opts, _ := action.GetValue().(metav1.GetNotesOptions)
+		rs, err := tracker.Get(ns, name, opts.Revision)
+		if err != nil {
+			return true, nil, err
+		}
+		return true, rs.Info.Notes, nil
+
+	case SubresourceHooks:
+		opts, _ := action.GetValue().(metav1.GetHooksOptions)
+		rs, err := tracker.Get(ns, name, opts.Revision)
+		if err != nil {
+			return true, nil, err
+		}
+		return true, rs.Hooks, nil
+	}
+
+	return false, nil, nil
+}
+
+// listReaction filters the releases like `helm list` does, by default only the
+// deployed and the failed releases are listed.
+func listReaction(tracker ReleaseTracker, action ListActionImpl) (bool, interface{}, error) {
+	opts := action.GetListOptions()
+	if len(opts.Continue) != 0 {
+		offset, err := strconv.Atoi(opts.Continue)
+		if err != nil || offset < 0 {
+			return true, nil, fmt.Errorf("invalid continue token %q", opts.Continue)
+		}
+		opts.Offset = offset
+	}
+
+	var filter *regexp.Regexp
+	if len(opts.Filter) != 0 {
+		var err error
+		if filter, err = regexp.Compile(opts.Filter); err != nil {
+			return true, nil, fmt.Errorf("invalid regular expression %q: %v", opts.Filter, err)
+		}
+	}
+
+	releases, err := tracker.List(action.GetNamespace())
+	if err != nil {
+		return true, nil, err
+	}
+
+	states := releaseutil.ListStates(opts)
+	var items []v1.ReleaseStatus
+	for _, rs := range releases {
+		if !states[rs.Info.Status] {
+			continue
+		}
+		if filter != nil && !filter.MatchString(rs.Name) {
+			continue
+		}
+		items = append(items, rs)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if opts.SortByDate {
+			return items[i].Info.LastDeployed.Before(items[j].Info.LastDeployed.Time)
+		}
+		return items[i].Name < items[j].Name
+	})
+	if opts.Reverse {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	if opts.Offset > len(items) {
+		opts.Offset = len(items)
+	}
+	items = items[opts.Offset:]
+	if opts.Max > 0 && len(items) > opts.Max {
+		items = items[:opts.Max]
+	}
+
+	list := &v1.ReleaseList{Items: make([]v1.Release, 0, len(items))}
+	for i := range items {
+		list.Items = append(list.Items, *releaseutil.FromStatus(&items[i]))
+	}
+	if opts.Max > 0 && len(list.Items) == opts.Max {
+		list.Continue = strconv.Itoa(opts.Offset + len(list.Items))
+	}
+
+	return true, list, nil
+}
+
+func repoReaction(tracker ReleaseTracker, action GenericActionImpl) (bool, interface{}, error) {
+	if action.GetResource() != ReposResource {
+		return false, nil, nil
+	}
+
+	switch action.GetVerb() {
+	case VerbList:
+		return true, &v1.RepoList{Items: tracker.ListRepos()}, nil
+	case VerbRemove:
+		names, _ := action.GetValue().([]string)
+		for _, name := range names {
+			if err := tracker.RemoveRepo(name); err != nil {
+				return true, nil, err
+			}
+		}
+		return true, nil, nil
+	case VerbUpdate:
+		names, _ := action.GetValue().([]string)
+		repos := tracker.ListRepos()
+		if len(repos) == 0 {
+			return true, nil, utilhelm.NewRepoNotFound("")
+		}
+		for _, name := range names {
+			if !hasRepo(repos, name) {
+				return true, nil, utilhelm.NewRepoNotFound(name)
+			}
+		}
+		return true, nil, nil
+	}
+
+	return false, nil, nil
+}
+
+type tracker struct {
+	lock sync.RWMutex
+	// releases are the revisions of each release keyed by namespace/name,
+	// oldest first.
+	releases map[string][]*v1.ReleaseStatus
+	repos    map[string]v1.Repo
+	// generated is the sequence of the generated release names.
+	generated int
+	// watchers are the watches keyed by namespace, the empty namespace means
+	// all namespaces.
+	watchers map[string][]*watch.FakeWatcher
+}
+
+// NewReleaseTracker returns an empty release tracker.
+func NewReleaseTracker() ReleaseTracker {
+	return &tracker{
+		releases: make(map[string][]*v1.ReleaseStatus),
+		repos:    make(map[string]v1.Repo),
+		watchers: make(map[string][]*watch.FakeWatcher),
+	}
+}
+
+func (t *tracker) Add(release v1.Release) error {
+	if len(release.Name) == 0 {
+		return fmt.Errorf("release name is required")
+	}
+
+	revision := 1
+	if len(release.Revision) != 0 {
+		var err error
+		if revision, err = strconv.Atoi(release.Revision); err != nil || revision < 1 {
+			return fmt.Errorf("invalid revision %q of release %q", release.Revision, release.Name)
+		}
+	}
+	status := v1.ReleasePhaseDeployed
+	if len(release.Status) != 0 {
+		status = v1.ReleasePhase(release.Status)
+	}
+	chartName, chartVersion := splitChart(release.Chart)
+
+	now := metav1.NewTime(time.Now())
+	rs := &v1.ReleaseStatus{
+		Name:      release.Name,
+		Namespace: namespaceOrDefault(release.Namespace),
+		Version:   revision,
+		Info: &v1.ReleaseInfo{
+			FirstDeployed: now,
+			LastDeployed:  now,
+			Status:        status,
+		},
+		Chart: &v1.Chart{
+			Metadata: &v1.ChartMetadata{
+				Name:       chartName,
+				Version:    chartVersion,
+				AppVersion: release.AppVersion,
+			},
+		},
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	key := releaseKey(rs.Namespace, rs.Name)
+	if _, ok := t.releases[key]; ok {
+		return utilhelm.NewAlreadyExists(rs.Name)
+	}
+	t.releases[key] = []*v1.ReleaseStatus{rs}
+	t.emit(watch.Added, rs)
+
+	return nil
+}
+
+func (t *tracker) Install(ns, name string, opts metav1.InstallOptions) (*v1.ReleaseStatus, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.install(ns, name, opts)
+}
+
+func (t *tracker) install(ns, name string, opts metav1.InstallOptions) (*v1.ReleaseStatus, error) {
+	chartName := chartNameFromReference(opts.ChartReference)
+	if opts.GenerateName || len(opts.NameTemplate) != 0 {
+		if len(name) != 0 {
+			return nil, fmt.Errorf("cannot set the release name with generate name or name template")
+		}
+		t.generated++
+		name = fmt.Sprintf("%s-%d", chartName, t.generated)
+	}
+	if len(name) == 0 {
+		return nil, fmt.Errorf("must either provide a name or specify generate name")
+	}
+
+	ns = namespaceOrDefault(ns)
+	key := releaseKey(ns, name)
+	if _, ok := t.releases[key]; ok {
+		return nil, utilhelm.NewAlreadyExists(name)
+	}
+	values, err := jsonValues(opts.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	now := metav1.NewTime(time.Now())
+	rs := &v1.ReleaseStatus{
+		Name:      name,
+		Namespace: ns,
+		Version:   1,
+		Info: &v1.ReleaseInfo{
+			FirstDeployed: now,
+			LastDeployed:  now,
+			Description:   "Install complete",
+			Status:        v1.ReleasePhaseDeployed,
+		},
+		Chart:  newChart(chartName, opts.Version),
+		Config: valuesFromSets(values, opts.ValuesSets),
+	}
+	t.releases[key] = []*v1.ReleaseStatus{rs}
+	t.emit(watch.Added, rs)
+
+	return copyReleaseStatus(rs), nil
+}
+
+func (t *tracker) Upgrade(ns, name string, opts metav1.UpgradeOptions) (*v1.ReleaseStatus, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	ns = namespaceOrDefault(ns)
+	revisions := t.releases[releaseKey(ns, name)]
+	if !opts.Install && (len(revisions) == 0 || revisions[len(revisions)-1].Info.Status == v1.ReleasePhaseUninstalled) {
+		return nil, &utilhelm.StatusError{
+			Reason:  utilhelm.StatusReasonReleaseNotFound,
+			Message: fmt.Sprintf("%q has no deployed releases", name),
+		}
+	}
+	if len(revisions) == 0 {
+		return t.install(ns, name, metav1.InstallOptions{
+			ChartReference:  opts.ChartReference,
+			CreateNamespace: opts.CreateNamespace,
+			Version:         opts.Version,
+			Wait:            opts.Wait,
+			ValuesFiles:     opts.ValuesFiles,
+			ValuesSets:      opts.ValuesSets,
+			Values:          opts.Values,
+		})
+	}
+
+	values, err := jsonValues(opts.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	last := revisions[len(revisions)-1]
+	var config map[string]interface{}
+	// helm reuses the last values if no values are given, unless reset
+	if opts.ReuseValues || (!opts.ResetValues && len(opts.ValuesSets) == 0 && len(opts.ValuesFiles) == 0 && len(opts.Values) == 0) {
+		config = runtime.DeepCopyJSON(last.Config)
+	}
+	if len(opts.Values) != 0 {
+		config = releaseutil.MergeValues(config, values)
+	}
+
+	now := metav1.NewTime(time.Now())
+	rs := &v1.ReleaseStatus{
+		Name:      name,
+		Namespace: ns,
+		Version:   last.Version + 1,
+		Info: &v1.ReleaseInfo{
+			FirstDeployed: last.Info.FirstDeployed,
+			LastDeployed:  now,
+			Description:   "Upgrade complete",
+			Status:        v1.ReleasePhaseDeployed,
+		},
+		Chart:  newChart(chartNameFromReference(opts.ChartReference), opts.Version),
+		Config: valuesFromSets(config, opts.ValuesSets),
+	}
+	t.supersede(revisions)
+	t.releases[releaseKey(ns, name)] = append(revisions, rs)
+	t.emit(watch.Modified, rs)
+
+	return copyReleaseStatus(rs), nil
+}
+
+func (t *tracker) Rollback(ns, name string, revision int, opts metav1.RollbackOptions) (*v1.ReleaseStatus, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	ns = namespaceOrDefault(ns)
+	revisions := t.releases[releaseKey(ns, name)]
+	if len(revisions) == 0 {
+		return nil, utilhelm.NewReleaseNotFound(name)
+	}
+	last := revisions[len(revisions)-1]
+	if revision == 0 {
+		revision = last.Version - 1
+	}
+	target := findRevision(revisions, revision)
+	if target == nil {
+		return nil, utilhelm.NewReleaseNotFound(name)
+	}
+
+	rs := copyReleaseStatus(target)
+	rs.Version = last.Version + 1
+	rs.Info.LastDeployed = metav1.NewTime(time.Now())
+	rs.Info.Deleted = metav1.Time{}
+	rs.Info.Description = fmt.Sprintf("Rollback to %d", revision)
+	rs.Info.Status = v1.ReleasePhaseDeployed
+	t.supersede(revisions)
+	t.releases[releaseKey(ns, name)] = append(revisions, rs)
+	t.emit(watch.Modified, rs)
+
+	return copyReleaseStatus(rs), nil
+}
+
+func (t *tracker) Delete(ns, name string, opts metav1.DeleteOptions) (*v1.ReleaseStatus, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	key := releaseKey(namespaceOrDefault(ns), name)
+	revisions := t.releases[key]
+	if len(revisions) == 0 || revisions[len(revisions)-1].Info.Status == v1.ReleasePhaseUninstalled {
+		return nil, utilhelm.NewReleaseNotFound(name)
+	}
+
+	last := revisions[len(revisions)-1]
+	rs := copyReleaseStatus(last)
+	if opts.DryRun {
+		return rs, nil
+	}
+	if !opts.KeepHistory {
+		delete(t.releases, key)
+		t.emit(watch.Deleted, last)
+		return rs, nil
+	}
+
+	last.Info.Status = v1.ReleasePhaseUninstalled
+	last.Info.Deleted = metav1.NewTime(time.Now())
+	last.Info.Description = "Uninstallation complete"
+	if len(opts.Description) != 0 {
+		last.Info.Description = opts.Description
+	}
+	t.emit(watch.Modified, last)
+
+	return rs, nil
+}
+
+func (t *tracker) Get(ns, name string, revision int) (*v1.ReleaseStatus, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	revisions := t.releases[releaseKey(namespaceOrDefault(ns), name)]
+	if len(revisions) == 0 {
+		return nil, utilhelm.NewReleaseNotFound(name)
+	}
+	if revision == 0 {
+		return copyReleaseStatus(revisions[len(revisions)-1]), nil
+	}
+	rs := findRevision(revisions, revision)
+	if rs == nil {
+		return nil, utilhelm.NewReleaseNotFound(name)
+	}
+
+	return copyReleaseStatus(rs), nil
+}
+
+func (t *tracker) History(ns, name string) ([]v1.ReleaseStatus, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	revisions := t.releases[releaseKey(namespaceOrDefault(ns), name)]
+	if len(revisions) == 0 {
+		return nil, utilhelm.NewReleaseNotFound(name)
+	}
+
+	history := make([]v1.ReleaseStatus, 0, len(revisions))
+	for _, rs := range revisions {
+		history = append(history, *copyReleaseStatus(rs))
+	}
+
+	return history, nil
+}
+
+func (t *tracker) List(ns string) ([]v1.ReleaseStatus, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	var releases []v1.ReleaseStatus
+	for _, revisions := range t.releases {
+		last := revisions[len(revisions)-1]
+		if len(ns) != 0 && last.Namespace != ns {
+			continue
+		}
+		releases = append(releases, *copyReleaseStatus(last))
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return releaseKey(releases[i].Namespace, releases[i].Name) < releaseKey(releases[j].Namespace, releases[j].Name)
+	})
+
+	return releases, nil
+}
+
+func (t *tracker) Watch(ns string) (watch.Interface, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	w := watch.NewFake()
+	t.watchers[ns] = append(t.watchers[ns], w)
+
+	return w, nil
+}
+
+func (t *tracker) AddRepo(repo v1.Repo) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if existing, ok := t.repos[repo.Name]; ok && existing.URL != repo.URL {
+		return &utilhelm.StatusError{
+			Reason:  utilhelm.StatusReasonAlreadyExists,
+			Message: fmt.Sprintf("repository name (%s) already exists, please specify a different name", repo.Name),
+		}
+	}
+	t.repos[repo.Name] = repo
+
+	return nil
+}
+
+func (t *tracker) ListRepos() []v1.Repo {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	repos := make([]v1.Repo, 0, len(t.repos))
+	for _, repo := range t.repos {
+		repos = append(repos, repo)
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].Name < repos[j].Name
+	})
+
+	return repos
+}
+
+func (t *tracker) RemoveRepo(name string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, ok := t.repos[name]; !ok {
+		return utilhelm.NewRepoNotFound(name)
+	}
+	delete(t.repos, name)
+
+	return nil
+}
+
+// supersede marks the deployed revisions as superseded, it must be called
+// with the lock held.
+func (t *tracker) supersede(revisions []*v1.ReleaseStatus) {
+	for _, rs := range revisions {
+		if rs.Info.Status == v1.ReleasePhaseDeployed {
+			rs.Info.Status = v1.ReleasePhaseSuperseded
+		}
+	}
+}
+
+// emit sends the change of a release to the watchers of its namespace and of
+// all namespaces, the stopped watchers are dropped. It must be called with
+// the lock held.
+func (t *tracker) emit(eventType watch.EventType, rs *v1.ReleaseStatus) {
+	for _, ns := range []string{rs.Namespace, ""} {
+		var watchers []*watch.FakeWatcher
+		for _, w := range t.watchers[ns] {
+			if w.IsStopped() {
+				continue
+			}
+			w.Action(eventType, releaseutil.FromStatus(rs))
+			watchers = append(watchers, w)
+		}
+		t.watchers[ns] = watchers
+	}
+}
+
+func findRevision(revisions []*v1.ReleaseStatus, revision int) *v1.ReleaseStatus {
+	for _, rs := range revisions {
+		if rs.Version == revision {
+			return rs
+		}
+	}
+
+	return nil
+}
+
+func hasRepo(repos []v1.Repo, name string) bool {
+	for _, repo := range repos {
+		if repo.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func releaseKey(ns, name string) string {
+	return ns + "/" + name
+}
+
+func namespaceOrDefault(ns string) string {
+	if len(ns) == 0 {
+		return defaultNamespace
+	}
+
+	return ns
+}
+
+func newChart(name string, version *string) *v1.Chart {
+	metadata := &v1.ChartMetadata{Name: name}
+	if version != nil {
+		metadata.Version = *version
+	}
+
+	return &v1.Chart{Metadata: metadata}
+}
+
+// chartNameFromReference returns the chart name of a chart reference, such as
+// `bitnami/nginx`, `./nginx` or `nginx-1.0.0.tgz`.
+func chartNameFromReference(ref string) string {
+	name := path.Base(strings.TrimSuffix(ref, "/"))
+	if strings.HasSuffix(name, ".tgz") {
+		name, _ = splitChart(strings.TrimSuffix(name, ".tgz"))
+	}
+
+	return name
+}
+
+// splitChart splits the `NAME-VERSION` chart of the release summary, the
+// version starts at the first dash followed by a digit.
+func splitChart(chart string) (string, string) {
+	for i := 0; i < len(chart)-1; i++ {
+		if chart[i] == '-' && chart[i+1] >= '0' && chart[i+1] <= '9' {
+			return chart[:i], chart[i+1:]
+		}
+	}
+
+	return chart, ""
+}
+
+// valuesFromSets merges the `--set` values into the values, the keys are
+// dotted paths and the values are parsed like helm does for the scalars.
+func valuesFromSets(values map[string]interface{}, sets map[string]string) map[string]interface{} {
+	if len(sets) == 0 {
+		return values
+	}
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+
+	for key, value := range sets {
+		parts := strings.Split(key, ".")
+		current := values
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := current[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				current[part] = next
+			}
+			current = next
+		}
+		current[parts[len(parts)-1]] = parseScalar(value)
+	}
+
+	return values
+}
+
+func parseScalar(value string) interface{} {
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+
+	return value
+}
+
+// jsonValues returns the values as helm reads them from a values file, e.g.
+// the numbers are float64.
+func jsonValues(values map[string]interface{}) (map[string]interface{}, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("marshal values failed %v", err)
+	}
+	var out map[string]interface{}
+	if err = json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("unmarshal values failed %v", err)
+	}
+
+	return out, nil
+}
+
+func copyReleaseStatus(rs *v1.ReleaseStatus) *v1.ReleaseStatus {
+	out := *rs
+	if rs.Info != nil {
+		info := *rs.Info
+		out.Info = &info
+	}
+	if rs.Chart != nil {
+		chart := v1.Chart{Values: runtime.DeepCopyJSON(rs.Chart.Values)}
+		if rs.Chart.Metadata != nil {
+			metadata := *rs.Chart.Metadata
+			chart.Metadata = &metadata
+		}
+		out.Chart = &chart
+	}
+	if rs.Config != nil {
+		out.Config = runtime.DeepCopyJSON(rs.Config)
+	}
+	if rs.Hooks != nil {
+		out.Hooks = append([]v1.Hook(nil), rs.Hooks...)
+	}
+
+	return &out
+}
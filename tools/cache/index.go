@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+
+	kubecache "k8s.io/client-go/tools/cache"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+)
+
+// NamespaceIndex is the name of the index of the releases by namespace.
+const NamespaceIndex = kubecache.NamespaceIndex
+
+// The types of the client-go cache, so that the informers can be used without
+// importing client-go.
+type (
+	Indexer                   = kubecache.Indexer
+	Indexers                  = kubecache.Indexers
+	IndexFunc                 = kubecache.IndexFunc
+	ResourceEventHandler      = kubecache.ResourceEventHandler
+	ResourceEventHandlerFuncs = kubecache.ResourceEventHandlerFuncs
+	InformerSynced            = kubecache.InformerSynced
+)
+
+// MetaNamespaceKeyFunc returns the namespace/name key of a release.
+func MetaNamespaceKeyFunc(obj interface{}) (string, error) {
+	if key, ok := obj.(string); ok {
+		return key, nil
+	}
+	r, ok := obj.(*v1.Release)
+	if !ok {
+		return "", fmt.Errorf("object has no meta: %T is not a release", obj)
+	}
+
+	return r.Namespace + "/" + r.Name, nil
+}
+
+// MetaNamespaceIndexFunc indexes the releases by namespace.
+func MetaNamespaceIndexFunc(obj interface{}) ([]string, error) {
+	r, ok := obj.(*v1.Release)
+	if !ok {
+		return nil, fmt.Errorf("object has no meta: %T is not a release", obj)
+	}
+
+	return []string{r.Namespace}, nil
+}
+
+// NewIndexer returns an Indexer of the releases with the given indexers.
+func NewIndexer(indexers Indexers) Indexer {
+	return kubecache.NewIndexer(MetaNamespaceKeyFunc, indexers)
+}
+
+// WaitForCacheSync waits for caches to populate. It returns true if it was
+// successful, false if the controller should shutdown.
+func WaitForCacheSync(stopCh <-chan struct{}, cacheSyncs ...InformerSynced) bool {
+	return kubecache.WaitForCacheSync(stopCh, cacheSyncs...)
+}
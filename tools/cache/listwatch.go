@@ -0,0 +1,53 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	"github.com/caoyingjunz/client-helm/pkg/watch"
+)
+
+// ListerWatcher is any object that knows how to perform an initial list and
+// start a watch on the releases.
+type ListerWatcher interface {
+	List(ctx context.Context, opts metav1.ListOptions) (*v1.ReleaseList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+}
+
+// ListFunc knows how to list the releases.
+type ListFunc func(ctx context.Context, opts metav1.ListOptions) (*v1.ReleaseList, error)
+
+// WatchFunc knows how to watch the releases.
+type WatchFunc func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+
+// ListWatch knows how to list and watch the releases. It satisfies the
+// ListerWatcher interface.
+type ListWatch struct {
+	ListFunc  ListFunc
+	WatchFunc WatchFunc
+}
+
+func (lw *ListWatch) List(ctx context.Context, opts metav1.ListOptions) (*v1.ReleaseList, error) {
+	return lw.ListFunc(ctx, opts)
+}
+
+func (lw *ListWatch) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	return lw.WatchFunc(ctx, opts)
+}
@@ -0,0 +1,374 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubecache "k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"github.com/caoyingjunz/client-helm/api/apps/v1"
+	metav1 "github.com/caoyingjunz/client-helm/api/meta/v1"
+	"github.com/caoyingjunz/client-helm/pkg/watch"
+)
+
+// relistPeriod is the wait before the releases are listed again once a watch
+// ends.
+const relistPeriod = time.Second
+
+// SharedInformer keeps a local cache of the releases and notifies the handlers
+// of the changes. Each handler is called from its own goroutine, in the order
+// of the changes, so a slow handler delays neither the cache nor the other
+// handlers, and a handler may call back into the informer. The handlers are
+// only notified of real changes: a release seen again with the same summary
+// is not an update, except at a resync.
+type SharedInformer interface {
+	// AddEventHandler adds a handler to the informer, the releases already in
+	// the cache are delivered to it as added.
+	AddEventHandler(handler ResourceEventHandler)
+	// GetStore returns the informer's local cache as a Store.
+	GetStore() kubecache.Store
+	// Run lists and watches the releases until the stopCh is closed, the
+	// releases are listed again whenever the watch ends.
+	Run(stopCh <-chan struct{})
+	// HasSynced returns true if the first list of the releases has been
+	// delivered to the handlers and the releases are watched.
+	HasSynced() bool
+}
+
+// SharedIndexInformer provides add and get Indexers ability based on SharedInformer.
+type SharedIndexInformer interface {
+	SharedInformer
+	// AddIndexers add indexers to the informer before it starts.
+	AddIndexers(indexers Indexers) error
+	GetIndexer() Indexer
+}
+
+// sharedIndexInformer implements SharedIndexInformer.
+type sharedIndexInformer struct {
+	indexer       Indexer
+	listerWatcher ListerWatcher
+	resyncPeriod  time.Duration
+
+	// lock serializes the changes of the cache and the added listeners, the
+	// notifications are queued to the listeners under it.
+	lock      sync.Mutex
+	listeners []*listener
+
+	stateLock sync.RWMutex
+	started   bool
+	stopped   bool
+	synced    bool
+}
+
+// NewSharedIndexInformer creates a new instance for the listwatcher. Every
+// resyncPeriod all the releases in the cache are delivered to the handlers as
+// updates, zero disables the resync.
+func NewSharedIndexInformer(lw ListerWatcher, resyncPeriod time.Duration, indexers Indexers) SharedIndexInformer {
+	return &sharedIndexInformer{
+		indexer:       NewIndexer(indexers),
+		listerWatcher: lw,
+		resyncPeriod:  resyncPeriod,
+	}
+}
+
+func (s *sharedIndexInformer) AddEventHandler(handler ResourceEventHandler) {
+	l := newListener(handler)
+
+	s.lock.Lock()
+	s.listeners = append(s.listeners, l)
+	for _, obj := range s.indexer.List() {
+		l.add(func(h ResourceEventHandler) { h.OnAdd(obj) })
+	}
+	s.lock.Unlock()
+
+	s.stateLock.RLock()
+	defer s.stateLock.RUnlock()
+	if s.started && !s.stopped {
+		l.start()
+	}
+}
+
+func (s *sharedIndexInformer) GetStore() kubecache.Store {
+	return s.indexer
+}
+
+func (s *sharedIndexInformer) GetIndexer() Indexer {
+	return s.indexer
+}
+
+func (s *sharedIndexInformer) AddIndexers(indexers Indexers) error {
+	s.stateLock.RLock()
+	defer s.stateLock.RUnlock()
+
+	if s.started {
+		return fmt.Errorf("informer has already started")
+	}
+	return s.indexer.AddIndexers(indexers)
+}
+
+func (s *sharedIndexInformer) HasSynced() bool {
+	s.stateLock.RLock()
+	defer s.stateLock.RUnlock()
+
+	return s.synced
+}
+
+func (s *sharedIndexInformer) Run(stopCh <-chan struct{}) {
+	s.stateLock.Lock()
+	if s.started {
+		s.stateLock.Unlock()
+		return
+	}
+	s.started = true
+	s.stateLock.Unlock()
+
+	s.lock.Lock()
+	for _, l := range s.listeners {
+		l.start()
+	}
+	s.lock.Unlock()
+	defer s.stopListeners()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := s.listAndWatch(ctx); err != nil {
+			klog.Errorf("list and watch releases failed: %v", err)
+		}
+	}, relistPeriod)
+}
+
+// stopListeners stops the listeners once the informer stops, the pending
+// notifications are dropped.
+func (s *sharedIndexInformer) stopListeners() {
+	s.stateLock.Lock()
+	s.stopped = true
+	s.stateLock.Unlock()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, l := range s.listeners {
+		l.stop()
+	}
+}
+
+// listAndWatch lists the releases to replace the cache, then applies the
+// changes from the watch until it ends.
+func (s *sharedIndexInformer) listAndWatch(ctx context.Context) error {
+	list, err := s.listerWatcher.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("list releases failed %v", err)
+	}
+	s.replace(list.Items)
+
+	w, err := s.listerWatcher.Watch(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("watch releases failed %v", err)
+	}
+	defer w.Stop()
+
+	// the cache is synced once the changes after the list are watched
+	s.stateLock.Lock()
+	s.synced = true
+	s.stateLock.Unlock()
+
+	var resyncCh <-chan time.Time
+	if s.resyncPeriod > 0 {
+		ticker := time.NewTicker(s.resyncPeriod)
+		defer ticker.Stop()
+		resyncCh = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-resyncCh:
+			s.resync()
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return nil
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				s.lock.Lock()
+				s.updateLocked(event.Object)
+				s.lock.Unlock()
+			case watch.Deleted:
+				s.lock.Lock()
+				s.deleteLocked(event.Object)
+				s.lock.Unlock()
+			case watch.Error:
+				return fmt.Errorf("watch releases failed %v", event.Err)
+			}
+		}
+	}
+}
+
+// replace makes the cache match the listed releases.
+func (s *sharedIndexInformer) replace(releases []v1.Release) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	keys := sets.NewString()
+	for i := range releases {
+		r := releases[i]
+		s.updateLocked(&r)
+		keys.Insert(r.Namespace + "/" + r.Name)
+	}
+	for _, key := range s.indexer.ListKeys() {
+		if keys.Has(key) {
+			continue
+		}
+		if obj, exists, _ := s.indexer.GetByKey(key); exists {
+			s.deleteLocked(obj.(*v1.Release))
+		}
+	}
+}
+
+func (s *sharedIndexInformer) resync() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, obj := range s.indexer.List() {
+		s.notifyLocked(func(h ResourceEventHandler) { h.OnUpdate(obj, obj) })
+	}
+}
+
+// updateLocked adds or updates a release in the cache, the handlers are only
+// notified if the release changed. It must be called with the lock held.
+func (s *sharedIndexInformer) updateLocked(r *v1.Release) {
+	old, exists, err := s.indexer.Get(r)
+	if err != nil {
+		klog.Errorf("get release %s/%s from cache failed: %v", r.Namespace, r.Name, err)
+		return
+	}
+	if exists && *old.(*v1.Release) == *r {
+		return
+	}
+	if err = s.indexer.Update(r); err != nil {
+		klog.Errorf("update release %s/%s in cache failed: %v", r.Namespace, r.Name, err)
+		return
+	}
+
+	if exists {
+		s.notifyLocked(func(h ResourceEventHandler) { h.OnUpdate(old, r) })
+	} else {
+		s.notifyLocked(func(h ResourceEventHandler) { h.OnAdd(r) })
+	}
+}
+
+// deleteLocked removes a release from the cache, the handlers are notified
+// with the cached release. It must be called with the lock held.
+func (s *sharedIndexInformer) deleteLocked(r *v1.Release) {
+	old, exists, err := s.indexer.Get(r)
+	if err != nil || !exists {
+		return
+	}
+	if err = s.indexer.Delete(old); err != nil {
+		klog.Errorf("delete release %s/%s from cache failed: %v", r.Namespace, r.Name, err)
+		return
+	}
+
+	s.notifyLocked(func(h ResourceEventHandler) { h.OnDelete(old) })
+}
+
+// notifyLocked queues the notification to the listeners. It must be called
+// with the lock held.
+func (s *sharedIndexInformer) notifyLocked(n notification) {
+	for _, l := range s.listeners {
+		l.add(n)
+	}
+}
+
+// notification calls a method of a handler.
+type notification func(h ResourceEventHandler)
+
+// listener delivers the notifications to a handler from its own goroutine,
+// the notifications are queued without limit so that the informer never
+// waits for the handler.
+type listener struct {
+	handler ResourceEventHandler
+
+	once    sync.Once
+	lock    sync.Mutex
+	cond    *sync.Cond
+	pending []notification
+	stopped bool
+}
+
+func newListener(handler ResourceEventHandler) *listener {
+	l := &listener{handler: handler}
+	l.cond = sync.NewCond(&l.lock)
+	return l
+}
+
+// start runs the listener, it is only started once.
+func (l *listener) start() {
+	l.once.Do(func() { go l.run() })
+}
+
+func (l *listener) add(n notification) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.pending = append(l.pending, n)
+	l.cond.Signal()
+}
+
+func (l *listener) stop() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.stopped = true
+	l.cond.Signal()
+}
+
+// run calls the handler with the notifications in order until it is stopped.
+func (l *listener) run() {
+	for {
+		l.lock.Lock()
+		for len(l.pending) == 0 && !l.stopped {
+			l.cond.Wait()
+		}
+		if l.stopped {
+			l.lock.Unlock()
+			return
+		}
+		n := l.pending[0]
+		l.pending[0] = nil
+		l.pending = l.pending[1:]
+		l.lock.Unlock()
+
+		n(l.handler)
+	}
+}
@@ -17,19 +17,105 @@ limitations under the License.
 package clientcmd
 
 import (
+	"fmt"
+	"os"
+
+	kubeclientcmd "k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/klog/v2"
 
 	restclient "github.com/caoyingjunz/client-helm/rest"
 )
 
-// BuildConfigFromFlags is a helper function that builds configs from a kubeconfig filepath.
-// These are passed in as command line flags for cluster components.
-// Warnings should reflect this usage. If kubeconfigPath
-// are passed in we fallback to ~/.kube/config.
-func BuildConfigFromFlags(kubeconfigPath string) (*restclient.Config, error) {
-	if kubeconfigPath == "" {
-		klog.Warningf("--kubeconfig was not specified. This might not work.")
+// BuildConfigFromFlags is a helper function that builds configs from a master
+// url or a kubeconfig filepath. These are passed in as command line flags for
+// cluster components. Warnings should reflect this usage. If neither masterURL
+// or kubeconfigPath are passed in we fallback to inClusterConfig. If
+// inClusterConfig fails, we fallback to the default config, which merges the
+// files listed in $KUBECONFIG, or uses ~/.kube/config.
+func BuildConfigFromFlags(masterURL, kubeconfigPath string) (*restclient.Config, error) {
+	if kubeconfigPath == "" && masterURL == "" {
+		klog.Warning("Neither --kubeconfig nor --master was specified.  Using the inClusterConfig.  This might not work.")
+		config, err := InClusterConfig()
+		if err == nil {
+			return config, nil
+		}
+		klog.Warning("error creating inClusterConfig, falling back to default config: ", err)
+	}
+	if kubeconfigPath != "" {
+		if _, err := os.Stat(kubeconfigPath); err != nil {
+			return nil, fmt.Errorf("invalid kubeconfig %s: %v", kubeconfigPath, err)
+		}
+	}
+
+	loadingRules := kubeclientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfigPath
+	return BuildConfigFromLoadingRules(loadingRules, &kubeclientcmd.ConfigOverrides{
+		ClusterInfo: clientcmdapi.Cluster{Server: masterURL},
+	})
+}
+
+// BuildConfigFromLoadingRules builds configs from the kubeconfig files of the
+// loading rules, the overrides, such as the current context, are passed to
+// helm by the global flags. The config is validated before being returned.
+func BuildConfigFromLoadingRules(loadingRules *kubeclientcmd.ClientConfigLoadingRules, overrides *kubeclientcmd.ConfigOverrides) (*restclient.Config, error) {
+	if overrides == nil {
+		overrides = &kubeclientcmd.ConfigOverrides{}
+	}
+	clientConfig := kubeclientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+	if _, err := clientConfig.ClientConfig(); err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, file := range loadingRules.GetLoadingPrecedence() {
+		if _, err := os.Stat(file); err == nil {
+			files = append(files, file)
+		}
 	}
 
-	return &restclient.Config{KubeConfig: kubeconfigPath}, nil
+	config := &restclient.Config{}
+	switch len(files) {
+	case 0:
+		// Nothing to pass, helm may still work with the overrides
+	case 1:
+		config.KubeConfig = files[0]
+	default:
+		// helm only accepts a single kubeconfig file, so merge the files
+		rawConfig, err := clientConfig.RawConfig()
+		if err != nil {
+			return nil, err
+		}
+		if config.KubeConfigData, err = kubeclientcmd.Write(rawConfig); err != nil {
+			return nil, err
+		}
+	}
+	applyOverrides(config, overrides)
+
+	return config, nil
+}
+
+// BuildConfigFromKubeconfigBytes builds configs from the content of a
+// kubeconfig, it is written to a temporary file when the client is created.
+func BuildConfigFromKubeconfigBytes(kubeconfig []byte) (*restclient.Config, error) {
+	clientConfig, err := kubeclientcmd.NewClientConfigFromBytes(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = clientConfig.ClientConfig(); err != nil {
+		return nil, err
+	}
+
+	return &restclient.Config{KubeConfigData: kubeconfig}, nil
+}
+
+// applyOverrides maps the overrides to the global flags of helm.
+func applyOverrides(config *restclient.Config, overrides *kubeclientcmd.ConfigOverrides) {
+	config.KubeContext = overrides.CurrentContext
+	config.APIServer = overrides.ClusterInfo.Server
+	config.CAFile = overrides.ClusterInfo.CertificateAuthority
+	config.InsecureSkipTLSVerify = overrides.ClusterInfo.InsecureSkipTLSVerify
+	config.BearerToken = overrides.AuthInfo.Token
+	config.ImpersonateUser = overrides.AuthInfo.Impersonate
+	config.ImpersonateGroups = overrides.AuthInfo.ImpersonateGroups
 }
@@ -0,0 +1,284 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	kubeclientcmd "k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// setEnv sets the environment variables for the test, an empty value unsets
+// the variable, they are restored once the test is done.
+func setEnv(t *testing.T, env map[string]string) {
+	for k, v := range env {
+		old, ok := os.LookupEnv(k)
+		if len(v) == 0 {
+			os.Unsetenv(k)
+		} else {
+			os.Setenv(k, v)
+		}
+		k := k
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(k, old)
+			} else {
+				os.Unsetenv(k)
+			}
+		})
+	}
+}
+
+// writeKubeconfig writes a kubeconfig with a single context named name to
+// the path, and returns the content.
+func writeKubeconfig(t *testing.T, path, name, server string) []byte {
+	config := clientcmdapi.NewConfig()
+	config.Clusters[name] = &clientcmdapi.Cluster{Server: server}
+	config.AuthInfos[name] = &clientcmdapi.AuthInfo{Token: name + "-token"}
+	config.Contexts[name] = &clientcmdapi.Context{Cluster: name, AuthInfo: name}
+	config.CurrentContext = name
+
+	data, err := kubeclientcmd.Write(*config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(path) != 0 {
+		if err = ioutil.WriteFile(path, data, 0600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	return data
+}
+
+func TestInClusterConfig(t *testing.T) {
+	writeFiles := func(dir string, files map[string]string) {
+		for name, content := range files {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		host      string
+		files     map[string]string
+		server    string
+		namespace string
+		expectErr bool
+	}{
+		{
+			name:      "not in cluster",
+			files:     map[string]string{"token": "abc", "ca.crt": "ca"},
+			expectErr: true,
+		},
+		{
+			name:      "missing token",
+			host:      "10.0.0.1",
+			files:     map[string]string{"ca.crt": "ca"},
+			expectErr: true,
+		},
+		{
+			name:      "missing ca",
+			host:      "10.0.0.1",
+			files:     map[string]string{"token": "abc"},
+			expectErr: true,
+		},
+		{
+			name:   "without namespace",
+			host:   "10.0.0.1",
+			files:  map[string]string{"token": "abc", "ca.crt": "ca"},
+			server: "https://10.0.0.1:443",
+		},
+		{
+			name:      "ipv6 with namespace",
+			host:      "fd00::1",
+			files:     map[string]string{"token": "abc", "ca.crt": "ca", "namespace": "kube-system\n"},
+			server:    "https://[fd00::1]:443",
+			namespace: "kube-system",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			port := "443"
+			if len(tc.host) == 0 {
+				port = ""
+			}
+			setEnv(t, map[string]string{"KUBERNETES_SERVICE_HOST": tc.host, "KUBERNETES_SERVICE_PORT": port})
+			dir := t.TempDir()
+			writeFiles(dir, tc.files)
+
+			config, err := inClusterConfig(dir + "/")
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// the token and the ca are referenced by file
+			kubeConfig, err := kubeclientcmd.Load(config.KubeConfigData)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			context := kubeConfig.Contexts[kubeConfig.CurrentContext]
+			cluster, authInfo := kubeConfig.Clusters[context.Cluster], kubeConfig.AuthInfos[context.AuthInfo]
+			if cluster.Server != tc.server || cluster.CertificateAuthority != filepath.Join(dir, "ca.crt") {
+				t.Errorf("unexpected cluster: %+v", cluster)
+			}
+			if authInfo.TokenFile != filepath.Join(dir, "token") || len(authInfo.Token) != 0 {
+				t.Errorf("unexpected auth info: %+v", authInfo)
+			}
+			if context.Namespace != tc.namespace {
+				t.Errorf("expected namespace %q, got %q", tc.namespace, context.Namespace)
+			}
+		})
+	}
+
+	setEnv(t, map[string]string{"KUBERNETES_SERVICE_HOST": "", "KUBERNETES_SERVICE_PORT": ""})
+	if _, err := inClusterConfig(t.TempDir() + "/"); err != ErrNotInCluster {
+		t.Errorf("expected ErrNotInCluster, got %v", err)
+	}
+}
+
+func TestBuildConfigFromLoadingRules(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first"), filepath.Join(dir, "second")
+	writeKubeconfig(t, first, "first", "https://first.example.com")
+	writeKubeconfig(t, second, "second", "https://second.example.com")
+	missing := filepath.Join(dir, "missing")
+
+	t.Run("single file", func(t *testing.T) {
+		config, err := BuildConfigFromLoadingRules(&kubeclientcmd.ClientConfigLoadingRules{ExplicitPath: first}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.KubeConfig != first || len(config.KubeConfigData) != 0 {
+			t.Errorf("unexpected config: %+v", config)
+		}
+	})
+
+	t.Run("merged files", func(t *testing.T) {
+		setEnv(t, map[string]string{"KUBECONFIG": first + string(filepath.ListSeparator) + missing + string(filepath.ListSeparator) + second})
+		config, err := BuildConfigFromLoadingRules(kubeclientcmd.NewDefaultClientConfigLoadingRules(), nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(config.KubeConfig) != 0 {
+			t.Errorf("expected the files to be merged, got kubeconfig %s", config.KubeConfig)
+		}
+		kubeConfig, err := kubeclientcmd.Load(config.KubeConfigData)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// the first file wins the current context
+		if kubeConfig.CurrentContext != "first" || len(kubeConfig.Contexts) != 2 {
+			t.Errorf("unexpected merged kubeconfig: %+v", kubeConfig)
+		}
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		caFile := filepath.Join(dir, "ca.crt")
+		if err := ioutil.WriteFile(caFile, []byte("ca"), 0600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		setEnv(t, map[string]string{"KUBECONFIG": first + string(filepath.ListSeparator) + second})
+		config, err := BuildConfigFromLoadingRules(kubeclientcmd.NewDefaultClientConfigLoadingRules(), &kubeclientcmd.ConfigOverrides{
+			CurrentContext: "second",
+			ClusterInfo: clientcmdapi.Cluster{
+				Server:               "https://override.example.com",
+				CertificateAuthority: caFile,
+			},
+			AuthInfo: clientcmdapi.AuthInfo{
+				Token:             "override-token",
+				Impersonate:       "admin",
+				ImpersonateGroups: []string{"ops"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.KubeContext != "second" || config.APIServer != "https://override.example.com" || config.CAFile != caFile ||
+			config.BearerToken != "override-token" || config.ImpersonateUser != "admin" || len(config.ImpersonateGroups) != 1 {
+			t.Errorf("unexpected config: %+v", config)
+		}
+	})
+
+	t.Run("unknown context", func(t *testing.T) {
+		_, err := BuildConfigFromLoadingRules(&kubeclientcmd.ClientConfigLoadingRules{ExplicitPath: first}, &kubeclientcmd.ConfigOverrides{CurrentContext: "unknown"})
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+}
+
+func TestBuildConfigFromFlags(t *testing.T) {
+	if _, err := BuildConfigFromFlags("", filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("expected error for a missing kubeconfig, got nil")
+	}
+
+	path := filepath.Join(t.TempDir(), "config")
+	writeKubeconfig(t, path, "test", "https://test.example.com")
+	config, err := BuildConfigFromFlags("https://master.example.com", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.KubeConfig != path || config.APIServer != "https://master.example.com" {
+		t.Errorf("unexpected config: %+v", config)
+	}
+}
+
+func TestBuildConfigFromKubeconfigBytes(t *testing.T) {
+	valid := writeKubeconfig(t, "", "test", "https://test.example.com")
+
+	testCases := []struct {
+		name      string
+		data      []byte
+		expectErr bool
+	}{
+		{name: "valid", data: valid},
+		{name: "invalid yaml", data: []byte("clusters: [\n"), expectErr: true},
+		{name: "empty", data: []byte{}, expectErr: true},
+		{name: "unknown current context", data: []byte("apiVersion: v1\nkind: Config\ncurrent-context: unknown\n"), expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := BuildConfigFromKubeconfigBytes(tc.data)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(config.KubeConfigData) != string(tc.data) || len(config.KubeConfig) != 0 {
+				t.Errorf("unexpected config: %+v", config)
+			}
+		})
+	}
+}
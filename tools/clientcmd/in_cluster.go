@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientcmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+
+	kubeclientcmd "k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	restclient "github.com/caoyingjunz/client-helm/rest"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount/"
+
+	inClusterName = "in-cluster"
+)
+
+// ErrNotInCluster is returned by InClusterConfig if it is not called in a pod.
+var ErrNotInCluster = errors.New("unable to load in-cluster configuration, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be defined")
+
+// InClusterConfig returns a config object which uses the service account
+// kubernetes gives to pods. It's intended for clients that expect to be
+// running inside a pod running on kubernetes. It will return ErrNotInCluster
+// if called from a process not running in a kubernetes environment.
+// The token and the CA are referenced by file, so that the rotated token is
+// picked up by helm.
+func InClusterConfig() (*restclient.Config, error) {
+	return inClusterConfig(serviceAccountDir)
+}
+
+func inClusterConfig(dir string) (*restclient.Config, error) {
+	tokenFile := dir + "token"
+	rootCAFile := dir + "ca.crt"
+
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if len(host) == 0 || len(port) == 0 {
+		return nil, ErrNotInCluster
+	}
+	if _, err := os.Stat(tokenFile); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(rootCAFile); err != nil {
+		return nil, err
+	}
+
+	kubeConfig := clientcmdapi.NewConfig()
+	kubeConfig.Clusters[inClusterName] = &clientcmdapi.Cluster{
+		Server:               "https://" + net.JoinHostPort(host, port),
+		CertificateAuthority: rootCAFile,
+	}
+	kubeConfig.AuthInfos[inClusterName] = &clientcmdapi.AuthInfo{
+		TokenFile: tokenFile,
+	}
+	kubeConfig.Contexts[inClusterName] = &clientcmdapi.Context{
+		Cluster:  inClusterName,
+		AuthInfo: inClusterName,
+	}
+	if ns, err := ioutil.ReadFile(dir + "namespace"); err == nil {
+		kubeConfig.Contexts[inClusterName].Namespace = strings.TrimSpace(string(ns))
+	}
+	kubeConfig.CurrentContext = inClusterName
+
+	data, err := kubeclientcmd.Write(*kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &restclient.Config{KubeConfigData: data}, nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientcmd
+
+import (
+	"fmt"
+	"strings"
+
+	kuberest "k8s.io/client-go/rest"
+	kubeclientcmd "k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	restclient "github.com/caoyingjunz/client-helm/rest"
+)
+
+const restConfigName = "client-go"
+
+// BuildConfigFromRESTConfig builds configs from a client-go rest config, so
+// that client-go and client-helm share one identity. The server, the TLS
+// settings, the credentials and the exec or auth provider plugins are written
+// to a minimal kubeconfig, which is materialized to a temporary file when the
+// client is created and removed when the client is closed.
+// Configs with a custom transport or transport wrapper can not be converted,
+// since helm can not reuse the credentials held by the transport.
+func BuildConfigFromRESTConfig(config *kuberest.Config) (*restclient.Config, error) {
+	if config == nil {
+		return nil, fmt.Errorf("rest config is required")
+	}
+	if config.Transport != nil {
+		return nil, fmt.Errorf("rest config with a custom transport is not supported")
+	}
+	if config.WrapTransport != nil {
+		return nil, fmt.Errorf("rest config with a transport wrapper is not supported")
+	}
+	if len(config.Host) == 0 {
+		return nil, fmt.Errorf("host must be set in the rest config")
+	}
+
+	server := config.Host
+	if !strings.Contains(server, "://") {
+		scheme := "http://"
+		if kuberest.IsConfigTransportTLS(*config) {
+			scheme = "https://"
+		}
+		server = scheme + server
+	}
+
+	kubeConfig := clientcmdapi.NewConfig()
+	kubeConfig.Clusters[restConfigName] = &clientcmdapi.Cluster{
+		Server:                   server,
+		TLSServerName:            config.ServerName,
+		InsecureSkipTLSVerify:    config.Insecure,
+		CertificateAuthority:     config.CAFile,
+		CertificateAuthorityData: config.CAData,
+	}
+	kubeConfig.AuthInfos[restConfigName] = &clientcmdapi.AuthInfo{
+		ClientCertificate:     config.CertFile,
+		ClientCertificateData: config.CertData,
+		ClientKey:             config.KeyFile,
+		ClientKeyData:         config.KeyData,
+		Token:                 config.BearerToken,
+		TokenFile:             config.BearerTokenFile,
+		Impersonate:           config.Impersonate.UserName,
+		ImpersonateGroups:     config.Impersonate.Groups,
+		ImpersonateUserExtra:  config.Impersonate.Extra,
+		Username:              config.Username,
+		Password:              config.Password,
+		AuthProvider:          config.AuthProvider,
+		Exec:                  config.ExecProvider,
+	}
+	kubeConfig.Contexts[restConfigName] = &clientcmdapi.Context{
+		Cluster:  restConfigName,
+		AuthInfo: restConfigName,
+	}
+	kubeConfig.CurrentContext = restConfigName
+
+	data, err := kubeclientcmd.Write(*kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &restclient.Config{
+		KubeConfigData: data,
+		BurstLimit:     config.Burst,
+	}, nil
+}